@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	defaultCanaryConfidence  = 0.95
+	defaultCanaryMinRequests = 1000
+)
+
+// Canary verdicts.
+const (
+	CanaryPass         = "pass"
+	CanaryFail         = "fail"
+	CanaryInconclusive = "inconclusive"
+)
+
+// CanaryVersion is the metrics of one side of a canary comparison.
+type CanaryVersion struct {
+	Version     string  `json:"version"`
+	Hosts       uint    `json:"hosts"`
+	Requests    uint    `json:"requests"`
+	Successes   uint    `json:"successes"`
+	SuccessRate float64 `json:"success_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+}
+
+// CanaryResult is the outcome of comparing a candidate version of an application with a baseline.
+type CanaryResult struct {
+	Application string        `json:"application"`
+	Baseline    CanaryVersion `json:"baseline"`
+	Candidate   CanaryVersion `json:"candidate"`
+	// Z is the statistic of a two-proportion z-test on the success rates.  It is positive when
+	// the candidate succeeds less often than the baseline.
+	Z float64 `json:"z"`
+	// PValue is the one-sided p-value of the candidate succeeding less often than the baseline.
+	PValue float64 `json:"p_value"`
+	// Confidence is the confidence with which the candidate succeeds less often, 1 - PValue.
+	Confidence float64 `json:"confidence"`
+	// RequiredConfidence is the confidence needed to fail the candidate.
+	RequiredConfidence float64 `json:"required_confidence"`
+	// MinRequests is the number of requests needed by each version to reach a verdict.
+	MinRequests uint   `json:"min_requests"`
+	Verdict     string `json:"verdict"`
+	Reason      string `json:"reason"`
+}
+
+// newCanaryVersion creates one side of a canary comparison from the metric of a version.
+func newCanaryVersion(version string, m Metric) CanaryVersion {
+	return CanaryVersion{
+		Version:     version,
+		Hosts:       m.HostCount,
+		Requests:    m.TotalRequestsCount,
+		Successes:   m.TotalSuccessCount,
+		SuccessRate: m.SuccessRate(),
+		ErrorRate:   m.ErrorRate(),
+	}
+}
+
+// AnalyzeCanary compares the success rate of the candidate version of an application with the
+// baseline version using a one-sided two-proportion z-test.  The candidate fails when it
+// succeeds less often than the baseline with at least the given confidence, and the verdict
+// is inconclusive when either version has fewer than minRequests requests.
+func AnalyzeCanary(app string, baseline, candidate CanaryVersion, minRequests uint, confidence float64) CanaryResult {
+	res := CanaryResult{
+		Application:        app,
+		Baseline:           baseline,
+		Candidate:          candidate,
+		RequiredConfidence: confidence,
+		MinRequests:        minRequests,
+	}
+
+	n1, n2 := float64(baseline.Requests), float64(candidate.Requests)
+	if baseline.Requests > 0 && candidate.Requests > 0 {
+		pooled := (float64(baseline.Successes) + float64(candidate.Successes)) / (n1 + n2)
+		se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+		diff := baseline.SuccessRate - candidate.SuccessRate
+		switch {
+		case se > 0:
+			res.Z = diff / se
+		case diff > 0:
+			res.Z = math.Inf(1)
+		case diff < 0:
+			res.Z = math.Inf(-1)
+		}
+		res.PValue = 1 - normalCDF(res.Z)
+		res.Confidence = 1 - res.PValue
+	} else {
+		res.PValue = 1
+	}
+
+	switch {
+	case baseline.Requests < minRequests || candidate.Requests < minRequests:
+		res.Verdict = CanaryInconclusive
+		res.Reason = fmt.Sprintf("baseline made %s and candidate %s requests, %s each are needed",
+			formatCount(baseline.Requests), formatCount(candidate.Requests), formatCount(minRequests))
+	case res.Confidence >= confidence:
+		res.Verdict = CanaryFail
+		res.Reason = fmt.Sprintf("candidate success rate is lower than baseline with %s confidence, %s required",
+			formatPercent(res.Confidence), formatPercent(confidence))
+	default:
+		res.Verdict = CanaryPass
+		res.Reason = fmt.Sprintf("candidate success rate is lower than baseline with only %s confidence, %s required",
+			formatPercent(res.Confidence), formatPercent(confidence))
+	}
+	return res
+}
+
+// normalCDF returns the standard normal cumulative distribution function at z.
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// runCanary polls every host once and compares the candidate version of an application with the
+// baseline version.  A failed candidate exits with exitCheckFailed, and an inconclusive verdict
+// with exitInconclusive.
+func runCanary(flag Flag) {
+	report := pollOnce(flag)
+
+	apps := report.Apps
+	if flag.CheckWindow {
+		apps = report.Windows
+	}
+	baseline := newCanaryVersion(flag.CanaryBaseline, apps[Application{Name: flag.CanaryApp, Version: flag.CanaryBaseline}])
+	candidate := newCanaryVersion(flag.CanaryCandidate, apps[Application{Name: flag.CanaryApp, Version: flag.CanaryCandidate}])
+	res := AnalyzeCanary(flag.CanaryApp, baseline, candidate, uint(flag.CanaryMinRequests), flag.CanaryConfidence)
+
+	var err error
+	if strings.EqualFold(flag.OutputFormat, "json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = errors.Wrap(enc.Encode(res), "unable to encode json canary result")
+	} else {
+		err = writeCanary(os.Stdout, res)
+	}
+	if err != nil {
+		log.WithError(err).Fatal("unable to write canary result")
+	}
+
+	switch res.Verdict {
+	case CanaryFail:
+		os.Exit(exitCheckFailed)
+	case CanaryInconclusive:
+		os.Exit(exitInconclusive)
+	}
+}
+
+// writeCanary writes both versions of a canary comparison as a table, followed by the verdict.
+func writeCanary(w io.Writer, res CanaryResult) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "canary %s: candidate %s against baseline %s\n\n", res.Application, res.Candidate.Version, res.Baseline.Version)
+
+	columns := []tableColumn{{header: ""}, {header: "VERSION"}, {header: "HOSTS", alignRight: true}, {header: "REQUESTS", alignRight: true}, {header: "SUCCESS RATE", alignRight: true}, {header: "ERROR RATE", alignRight: true}}
+	var cells [][]string
+	for _, v := range []struct {
+		role string
+		CanaryVersion
+	}{{"baseline", res.Baseline}, {"candidate", res.Candidate}} {
+		cells = append(cells, []string{v.role, v.Version, formatCount(v.Hosts), formatCount(v.Requests), formatPercent(v.SuccessRate), formatPercent(v.ErrorRate)})
+	}
+	writeTableCells(&b, columns, cells)
+
+	fmt.Fprintf(&b, "\nz = %.3f, p = %.4f\n", res.Z, res.PValue)
+	fmt.Fprintf(&b, "verdict: %s, %s\n", strings.ToUpper(res.Verdict), res.Reason)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.Wrap(err, "unable to write canary result")
+	}
+	return nil
+}
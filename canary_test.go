@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func canaryVersion(version string, requests, successes uint) CanaryVersion {
+	return newCanaryVersion(version, Metric{TotalRequestsCount: requests, TotalSuccessCount: successes, TotalErrorCount: requests - successes, HostCount: 1})
+}
+
+func TestAnalyzingCanaries(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name       string
+		baseline   CanaryVersion
+		candidate  CanaryVersion
+		expVerdict string
+	}{
+		{"candidate clearly worse", canaryVersion("1.0.0", 10000, 9900), canaryVersion("1.1.0", 10000, 9800), CanaryFail},
+		{"candidate slightly worse", canaryVersion("1.0.0", 10000, 9900), canaryVersion("1.1.0", 10000, 9895), CanaryPass},
+		{"candidate better", canaryVersion("1.0.0", 10000, 9800), canaryVersion("1.1.0", 10000, 9900), CanaryPass},
+		{"both perfect", canaryVersion("1.0.0", 5000, 5000), canaryVersion("1.1.0", 5000, 5000), CanaryPass},
+		{"candidate too quiet", canaryVersion("1.0.0", 10000, 9900), canaryVersion("1.1.0", 999, 500), CanaryInconclusive},
+		{"candidate missing", canaryVersion("1.0.0", 10000, 9900), canaryVersion("1.1.0", 0, 0), CanaryInconclusive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := AnalyzeCanary("app1", tt.baseline, tt.candidate, 1000, 0.95)
+			assert.Equal(tt.expVerdict, res.Verdict, res.Reason)
+			assert.False(math.IsNaN(res.Z))
+			assert.InDelta(1, res.PValue+res.Confidence, 1e-9)
+		})
+	}
+}
+
+func TestAnalyzingCanaryStatistics(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// 200/1000 against 250/1000 failures, pooled rate 0.225: z = 0.05 / sqrt(0.225 * 0.775 * 0.002) = 2.677
+	res := AnalyzeCanary("app1", canaryVersion("1.0.0", 1000, 800), canaryVersion("1.1.0", 1000, 750), 100, 0.99)
+	assert.InDelta(2.6774, res.Z, 1e-4)
+	assert.InDelta(0.00371, res.PValue, 1e-5)
+	assert.Equal(CanaryFail, res.Verdict)
+
+	var buf bytes.Buffer
+	assert.Nil(writeCanary(&buf, res))
+	assert.True(strings.HasPrefix(buf.String(), "canary app1: candidate 1.1.0 against baseline 1.0.0\n"))
+	assert.Contains(buf.String(), "verdict: FAIL, candidate success rate is lower than baseline with 99.63% confidence, 99.00% required\n")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	defaultConnectTimeout      = 5 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+	defaultRequestTimeout      = 15 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// ClientConfig configures the HTTP client shared by all hosts.
+type ClientConfig struct {
+	// ConnectTimeout limits how long establishing a TCP connection may take.
+	ConnectTimeout time.Duration
+	// TLSHandshakeTimeout limits how long a TLS handshake may take.
+	TLSHandshakeTimeout time.Duration
+	// Timeout limits the total time of a single request, including reading the response body.
+	Timeout time.Duration
+	// MaxIdleConnsPerHost is the number of keep-alive connections held open for each remote host.
+	MaxIdleConnsPerHost int
+}
+
+// NewHTTPClient creates an *http.Client with the timeouts in cfg and keep-alive connection
+// pooling, suitable for sharing between every Host in a run.
+func NewHTTPClient(cfg ClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		MaxIdleConns:        cfg.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var defaultDiffThreshold = 0.01
+
+// ReportDiff holds the differences between two reports.
+type ReportDiff struct {
+	// Threshold is the smallest change in success rate reported.
+	Threshold float64 `json:"threshold"`
+	Hosts     struct {
+		Before HostsDocument `json:"before"`
+		After  HostsDocument `json:"after"`
+	} `json:"hosts"`
+	// Appeared lists application versions only in the later report.
+	Appeared []ApplicationDocument `json:"appeared"`
+	// Disappeared lists application versions only in the earlier report.
+	Disappeared []ApplicationDocument `json:"disappeared"`
+	// Changed lists application versions in both reports whose success rate changed by at least
+	// the threshold, or which are reported by a different number of hosts.
+	Changed []ApplicationChange `json:"changed"`
+	// NewlyFailing lists hosts which failed in the later report but not in the earlier one.
+	NewlyFailing []FailedHostDocument `json:"newly_failing_hosts"`
+	// Regressions is the number of success rate drops and newly failing hosts.
+	Regressions int `json:"regressions"`
+}
+
+// ApplicationChange describes how an application version changed between two reports.
+type ApplicationChange struct {
+	Name              string            `json:"name"`
+	Version           string            `json:"version"`
+	Groups            map[string]string `json:"groups,omitempty"`
+	SuccessRateBefore float64           `json:"success_rate_before"`
+	SuccessRateAfter  float64           `json:"success_rate_after"`
+	SuccessRateChange float64           `json:"success_rate_change"`
+	HostsBefore       uint              `json:"hosts_before"`
+	HostsAfter        uint              `json:"hosts_after"`
+	// Regression is set when the success rate dropped by at least the threshold.
+	Regression bool `json:"regression"`
+}
+
+// DiffReports compares the report before with the later report after.  Success rate changes
+// smaller than threshold are ignored.
+func DiffReports(before, after ReportDocument, threshold float64) ReportDiff {
+	d := ReportDiff{
+		Threshold:    threshold,
+		Appeared:     []ApplicationDocument{},
+		Disappeared:  []ApplicationDocument{},
+		Changed:      []ApplicationChange{},
+		NewlyFailing: []FailedHostDocument{},
+	}
+	d.Hosts.Before = before.Hosts
+	d.Hosts.After = after.Hosts
+
+	beforeApps := make(map[string]ApplicationDocument)
+	for _, app := range before.Applications {
+		beforeApps[app.key()] = app
+	}
+	afterApps := make(map[string]bool)
+	for _, app := range after.Applications {
+		afterApps[app.key()] = true
+		prev, ok := beforeApps[app.key()]
+		if !ok {
+			d.Appeared = append(d.Appeared, app)
+			continue
+		}
+
+		change := ApplicationChange{
+			Name:              app.Name,
+			Version:           app.Version,
+			Groups:            app.Groups,
+			SuccessRateBefore: prev.SuccessRate,
+			SuccessRateAfter:  app.SuccessRate,
+			SuccessRateChange: app.SuccessRate - prev.SuccessRate,
+			HostsBefore:       prev.Hosts,
+			HostsAfter:        app.Hosts,
+		}
+		// a threshold of zero reports every change, but an unchanged rate is never a change
+		change.Regression = change.SuccessRateChange < 0 && -change.SuccessRateChange >= threshold
+		rateChanged := change.SuccessRateChange > 0 && change.SuccessRateChange >= threshold || change.Regression
+		if rateChanged || change.HostsBefore != change.HostsAfter {
+			d.Changed = append(d.Changed, change)
+		}
+		if change.Regression {
+			d.Regressions++
+		}
+	}
+	for _, app := range before.Applications {
+		if !afterApps[app.key()] {
+			d.Disappeared = append(d.Disappeared, app)
+		}
+	}
+
+	failedBefore := make(map[string]bool)
+	for _, f := range before.FailedHosts {
+		failedBefore[f.key()] = true
+	}
+	for _, f := range after.FailedHosts {
+		if !failedBefore[f.key()] {
+			d.NewlyFailing = append(d.NewlyFailing, f)
+			d.Regressions++
+		}
+	}
+
+	sortApplicationDocuments(d.Appeared)
+	sortApplicationDocuments(d.Disappeared)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return compareApplications(d.Changed[i].Name, d.Changed[i].Version, d.Changed[j].Name, d.Changed[j].Version) < 0
+	})
+	sort.Slice(d.NewlyFailing, func(i, j int) bool {
+		if d.NewlyFailing[i].Host != d.NewlyFailing[j].Host {
+			return d.NewlyFailing[i].Host < d.NewlyFailing[j].Host
+		}
+		return d.NewlyFailing[i].URL < d.NewlyFailing[j].URL
+	})
+	return d
+}
+
+// key identifies the failed host across reports by its status URL, as hosts of the same name may
+// be queried at different URLs.  The name is used when the URL is not known, as for hosts
+// whose URL could not be created.
+func (f FailedHostDocument) key() string {
+	if f.URL == "" {
+		return f.Host
+	}
+	return f.URL
+}
+
+// key identifies the application version and group of app across reports.
+func (app ApplicationDocument) key() string {
+	names := make([]string, 0, len(app.Groups))
+	for name := range app.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{app.Name, app.Version}
+	for _, name := range names {
+		parts = append(parts, name+"="+app.Groups[name])
+	}
+	return strings.Join(parts, groupSeparator)
+}
+
+// sortApplicationDocuments orders apps by name and version.
+func sortApplicationDocuments(apps []ApplicationDocument) {
+	sort.Slice(apps, func(i, j int) bool {
+		if c := compareApplications(apps[i].Name, apps[i].Version, apps[j].Name, apps[j].Version); c != 0 {
+			return c < 0
+		}
+		return apps[i].key() < apps[j].key()
+	})
+}
+
+// compareApplications orders applications by name and then by version.
+func compareApplications(nameA, versionA, nameB, versionB string) int {
+	if c := strings.Compare(nameA, nameB); c != 0 {
+		return c
+	}
+	return compareVersions(versionA, versionB)
+}
+
+// LoadReportDocument reads a JSON report written by the json output format.
+func LoadReportDocument(path string) (ReportDocument, error) {
+	var doc ReportDocument
+	f, err := os.Open(path)
+	if err != nil {
+		return doc, errors.Wrapf(err, "unable to open report '%s'", path)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return doc, errors.Wrapf(err, "unable to read report '%s'", path)
+	}
+	if doc.SchemaVersion < 1 || doc.SchemaVersion > reportSchemaVersion {
+		return doc, errors.Errorf("report '%s' has unsupported schema version %d", path, doc.SchemaVersion)
+	}
+	return doc, nil
+}
+
+// runDiff compares two saved JSON reports, exiting with exitCheckFailed when the later report
+// has regressed.
+func runDiff(flag Flag) {
+	before, err := LoadReportDocument(flag.DiffBefore)
+	if err != nil {
+		log.WithError(err).Fatal("unable to load report")
+	}
+	after, err := LoadReportDocument(flag.DiffAfter)
+	if err != nil {
+		log.WithError(err).Fatal("unable to load report")
+	}
+
+	d := DiffReports(before, after, flag.DiffThreshold)
+	if strings.EqualFold(flag.OutputFormat, "json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = errors.Wrap(enc.Encode(d), "unable to encode json diff")
+	} else {
+		err = writeDiff(os.Stdout, d)
+	}
+	if err != nil {
+		log.WithError(err).Fatal("unable to write diff")
+	}
+
+	if d.Regressions > 0 {
+		os.Exit(exitCheckFailed)
+	}
+}
+
+// grouped returns whether any application in d is grouped by dimensions other than application
+// and version.
+func (d ReportDiff) grouped() bool {
+	for _, app := range append(append([]ApplicationDocument{}, d.Appeared...), d.Disappeared...) {
+		if len(app.Groups) > 0 {
+			return true
+		}
+	}
+	for _, c := range d.Changed {
+		if len(c.Groups) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// formatGroups formats group dimensions as comma separated NAME=VALUE pairs, ordered by name.
+func formatGroups(groups map[string]string) string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + groups[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// writeDiff writes each section of d which has any differences as a table.
+func writeDiff(w io.Writer, d ReportDiff) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "hosts: %d -> %d total, %d -> %d reported, %d -> %d failed\n",
+		d.Hosts.Before.Total, d.Hosts.After.Total,
+		d.Hosts.Before.Reported, d.Hosts.After.Reported,
+		d.Hosts.Before.Failed, d.Hosts.After.Failed)
+
+	// applications are identified by their group too when the reports are grouped
+	grouped := d.grouped()
+	identity := func(name, version string, groups map[string]string) []string {
+		if grouped {
+			return []string{name, version, formatGroups(groups)}
+		}
+		return []string{name, version}
+	}
+	identityColumns := []tableColumn{{header: "APPLICATION"}, {header: "VERSION"}}
+	if grouped {
+		identityColumns = append(identityColumns, tableColumn{header: "GROUP"})
+	}
+
+	appColumns := append(append([]tableColumn{}, identityColumns...), tableColumn{header: "HOSTS", alignRight: true}, tableColumn{header: "REQUESTS", alignRight: true}, tableColumn{header: "SUCCESS RATE", alignRight: true})
+	appCells := func(apps []ApplicationDocument) [][]string {
+		cells := make([][]string, len(apps))
+		for i, app := range apps {
+			cells[i] = append(identity(app.Name, app.Version, app.Groups), formatCount(app.Hosts), formatCount(app.Requests), formatPercent(app.SuccessRate))
+		}
+		return cells
+	}
+	if len(d.Appeared) > 0 {
+		b.WriteString("\nappeared:\n")
+		writeTableCells(&b, appColumns, appCells(d.Appeared))
+	}
+	if len(d.Disappeared) > 0 {
+		b.WriteString("\ndisappeared:\n")
+		writeTableCells(&b, appColumns, appCells(d.Disappeared))
+	}
+
+	if len(d.Changed) > 0 {
+		b.WriteString("\nchanged:\n")
+		columns := append(append([]tableColumn{}, identityColumns...), tableColumn{header: "HOSTS", alignRight: true}, tableColumn{header: "SUCCESS RATE", alignRight: true}, tableColumn{header: "CHANGE"}, tableColumn{header: ""})
+		cells := make([][]string, len(d.Changed))
+		for i, c := range d.Changed {
+			var regression string
+			if c.Regression {
+				regression = "REGRESSION"
+			}
+			cells[i] = append(identity(c.Name, c.Version, c.Groups),
+				fmt.Sprintf("%d -> %d", c.HostsBefore, c.HostsAfter),
+				fmt.Sprintf("%s -> %s", formatPercent(c.SuccessRateBefore), formatPercent(c.SuccessRateAfter)),
+				formatRateTrend(c.SuccessRateChange),
+				regression,
+			)
+		}
+		writeTableCells(&b, columns, cells)
+	}
+
+	if len(d.NewlyFailing) > 0 {
+		b.WriteString("\nnewly failing hosts:\n")
+		columns := []tableColumn{{header: "HOST"}, {header: "CATEGORY"}, {header: "MESSAGE"}}
+		cells := make([][]string, len(d.NewlyFailing))
+		for i, f := range d.NewlyFailing {
+			cells[i] = []string{f.Host, f.Category, f.Message}
+		}
+		writeTableCells(&b, columns, cells)
+	}
+
+	fmt.Fprintf(&b, "\n%d regressions found\n", d.Regressions)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.Wrap(err, "unable to write diff")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffingReports(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	before := ReportDocument{
+		SchemaVersion: reportSchemaVersion,
+		Hosts:         HostsDocument{Total: 4, Reported: 3, Failed: 1},
+		Applications: []ApplicationDocument{
+			{Name: "app1", Version: "1.0.0", SuccessRate: 0.99, Hosts: 2},
+			{Name: "app2", Version: "1.0.0", SuccessRate: 0.95, Hosts: 1},
+			{Name: "app3", Version: "1.0.0", SuccessRate: 0.90, Hosts: 1},
+		},
+		FailedHosts: []FailedHostDocument{{Host: "host4", Category: "timeout"}},
+	}
+	after := ReportDocument{
+		SchemaVersion: reportSchemaVersion,
+		Hosts:         HostsDocument{Total: 4, Reported: 2, Failed: 2},
+		Applications: []ApplicationDocument{
+			{Name: "app1", Version: "1.0.0", SuccessRate: 0.985, Hosts: 1},
+			{Name: "app2", Version: "1.0.0", SuccessRate: 0.90, Hosts: 1},
+			{Name: "app3", Version: "1.1.0", SuccessRate: 0.99, Hosts: 1},
+		},
+		FailedHosts: []FailedHostDocument{{Host: "host4", Category: "timeout"}, {Host: "host1", Category: "dns"}},
+	}
+
+	d := DiffReports(before, after, 0.01)
+	assert.Equal(2, d.Regressions)
+	assert.Equal(HostsDocument{Total: 4, Reported: 2, Failed: 2}, d.Hosts.After)
+	assert.Equal([]ApplicationDocument{after.Applications[2]}, d.Appeared)
+	assert.Equal([]ApplicationDocument{before.Applications[2]}, d.Disappeared)
+	if assert.Len(d.Changed, 2) {
+		assert.Equal("app1", d.Changed[0].Name)
+		assert.False(d.Changed[0].Regression)
+		assert.Equal(uint(2), d.Changed[0].HostsBefore)
+		assert.Equal(uint(1), d.Changed[0].HostsAfter)
+		assert.Equal("app2", d.Changed[1].Name)
+		assert.True(d.Changed[1].Regression)
+	}
+	assert.Equal([]FailedHostDocument{{Host: "host1", Category: "dns"}}, d.NewlyFailing)
+
+	var buf bytes.Buffer
+	assert.Nil(writeDiff(&buf, d))
+	assert.Contains(buf.String(), "hosts: 4 -> 4 total, 3 -> 2 reported, 1 -> 2 failed\n")
+	assert.Contains(buf.String(), "REGRESSION")
+	assert.True(strings.HasSuffix(buf.String(), "2 regressions found\n"))
+
+	d = DiffReports(before, before, 0.01)
+	assert.Equal(0, d.Regressions)
+	assert.Empty(d.Changed)
+	assert.Empty(d.NewlyFailing)
+
+	d = DiffReports(before, after, 0)
+	assert.Equal(3, d.Regressions)
+	if assert.Len(d.Changed, 2) {
+		assert.True(d.Changed[0].Regression)
+	}
+
+	d = DiffReports(before, before, 0)
+	assert.Equal(0, d.Regressions)
+	assert.Empty(d.Changed)
+
+	// a host of the same name at another URL is a different host
+	before.FailedHosts = []FailedHostDocument{{Host: "host5", URL: "http://host5:8080/status"}}
+	after.FailedHosts = []FailedHostDocument{{Host: "host5", URL: "http://host5:8080/status"}, {Host: "host5", URL: "http://host5:8081/status"}}
+	d = DiffReports(before, after, 0.01)
+	assert.Equal([]FailedHostDocument{{Host: "host5", URL: "http://host5:8081/status"}}, d.NewlyFailing)
+}
+
+func TestLoadingReportDocuments(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.json")
+	var buf bytes.Buffer
+	assert.Nil(renderJSON(&buf, tableTestReport()))
+	assert.Nil(ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	doc, err := LoadReportDocument(path)
+	assert.Nil(err)
+	assert.Len(doc.Applications, 2)
+
+	// reports written before applications could be grouped are still compared
+	assert.Nil(ioutil.WriteFile(path, []byte(`{"schema_version": 1, "applications": [{"name": "app1", "version": "v1"}]}`), 0644))
+	doc, err = LoadReportDocument(path)
+	assert.Nil(err)
+	assert.Equal(1, doc.SchemaVersion)
+
+	assert.Nil(ioutil.WriteFile(path, []byte(`{"schema_version": 99}`), 0644))
+	_, err = LoadReportDocument(path)
+	assert.NotNil(err)
+
+	_, err = LoadReportDocument(filepath.Join(dir, "missing.json"))
+	assert.NotNil(err)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discovery kinds.
+const (
+	// DiscoverSRV discovers a host for every target of the SRV records of a name.
+	DiscoverSRV = "dns-srv"
+	// DiscoverDNS discovers a host for every address of the A and AAAA records of a name.
+	DiscoverDNS = "dns"
+)
+
+// discoveryTimeout limits how long resolving every discovery source may take.
+var discoveryTimeout = 10 * time.Second
+
+// Discovery is a source of hosts found by resolving DNS records.
+type Discovery struct {
+	// Kind is one of DiscoverSRV or DiscoverDNS.
+	Kind string
+	// Name is the DNS name resolved.
+	Name string
+	// Port is the port of every address discovered by DiscoverDNS, or zero to query the
+	// addresses through the root URL.  SRV records give their own ports.
+	Port int
+}
+
+// ParseDiscovery parses a discovery source given as dns-srv:NAME or dns:NAME[:PORT].
+func ParseDiscovery(value string) (Discovery, error) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return Discovery{}, errors.Errorf("discovery source '%s' must be given as dns-srv:NAME or dns:NAME[:PORT]", value)
+	}
+	d := Discovery{Kind: strings.ToLower(value[:i]), Name: strings.TrimSpace(value[i+1:])}
+
+	switch d.Kind {
+	case DiscoverSRV:
+	case DiscoverDNS:
+		if name, port, err := net.SplitHostPort(d.Name); err == nil {
+			p, err := strconv.Atoi(port)
+			if err != nil || p < 1 || p > 65535 {
+				return d, errors.Errorf("discovery source '%s' has invalid port '%s'", value, port)
+			}
+			d.Name, d.Port = name, p
+		}
+	default:
+		return d, errors.Errorf("unknown discovery kind '%s', expected %s or %s", d.Kind, DiscoverSRV, DiscoverDNS)
+	}
+	if d.Name == "" {
+		return d, errors.Errorf("discovery source '%s' has no name", value)
+	}
+	return d, nil
+}
+
+// ParseDiscoveries parses every discovery source.
+func ParseDiscoveries(values []string) ([]Discovery, error) {
+	var discoveries []Discovery
+	for _, v := range values {
+		d, err := ParseDiscovery(v)
+		if err != nil {
+			return nil, err
+		}
+		discoveries = append(discoveries, d)
+	}
+	return discoveries, nil
+}
+
+// String returns the discovery source as it is given on the command line.
+func (d Discovery) String() string {
+	if d.Port != 0 {
+		return d.Kind + ":" + net.JoinHostPort(d.Name, strconv.Itoa(d.Port))
+	}
+	return d.Kind + ":" + d.Name
+}
+
+// Hosts resolves the hosts of the discovery source, ordered by name and port.  Every host found
+// records the discovery source as its Source.  SRV records are keyed by target and port, so a
+// target serving on several ports is a host for each of them.
+func (d Discovery) Hosts(ctx context.Context, resolver *net.Resolver) ([]Host, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var hosts []Host
+	switch d.Kind {
+	case DiscoverSRV:
+		_, records, err := resolver.LookupSRV(ctx, "", "", d.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve SRV records of '%s'", d.Name)
+		}
+		seen := make(map[string]bool, len(records))
+		for _, srv := range records {
+			target := strings.TrimSuffix(srv.Target, ".")
+			// a target of . means the service is decidedly not available at the name
+			if target == "" {
+				continue
+			}
+			// records differing only by priority or weight name the same host
+			key := net.JoinHostPort(target, strconv.Itoa(int(srv.Port)))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			hosts = append(hosts, Host{Name: target, Port: int(srv.Port), Source: d.String()})
+		}
+	case DiscoverDNS:
+		addrs, err := resolver.LookupIPAddr(ctx, d.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve addresses of '%s'", d.Name)
+		}
+		for _, addr := range addrs {
+			hosts = append(hosts, Host{Name: addr.IP.String(), Port: d.Port, Source: d.String()})
+		}
+	default:
+		return nil, errors.Errorf("unknown discovery kind '%s'", d.Kind)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Name != hosts[j].Name {
+			return hosts[i].Name < hosts[j].Name
+		}
+		return hosts[i].Port < hosts[j].Port
+	})
+	return hosts, nil
+}
+
+// NewResolver creates a resolver which sends every query to the DNS server at address, using
+// port 53 when address has none.  The system resolver is used when address is empty.
+func NewResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+
+	var dialer net.Dialer
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
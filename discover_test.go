@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeSRV  = 33
+	dnsTypeAAAA = 28
+)
+
+// testDNSServer answers A, AAAA and SRV queries over UDP from the records it holds, standing in
+// for a DNS server.
+type testDNSServer struct {
+	conn net.PacketConn
+
+	mu    sync.Mutex
+	srv   map[string][]net.SRV
+	addrs map[string][]net.IP
+}
+
+func newTestDNSServer(t *testing.T) *testDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testDNSServer{conn: conn, srv: make(map[string][]net.SRV), addrs: make(map[string][]net.IP)}
+	go s.serve()
+	return s
+}
+
+func (s *testDNSServer) Addr() string { return s.conn.LocalAddr().String() }
+func (s *testDNSServer) Close()       { s.conn.Close() }
+
+func (s *testDNSServer) SetSRV(name string, records ...net.SRV) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.srv[name+"."] = records
+}
+
+func (s *testDNSServer) SetAddrs(name string, addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addrs[name+"."] = nil
+	for _, a := range addrs {
+		s.addrs[name+"."] = append(s.addrs[name+"."], net.ParseIP(a))
+	}
+}
+
+func (s *testDNSServer) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.srv, name+".")
+	delete(s.addrs, name+".")
+}
+
+func (s *testDNSServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.answer(buf[:n]); resp != nil {
+			s.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// answer builds the response to a query holding a single question.
+func (s *testDNSServer) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	var labels []string
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		l := int(query[end])
+		if end+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[end+1:end+1+l]))
+		end += 1 + l
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, ".")) + "."
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	s.mu.Lock()
+	var answers [][]byte
+	srv, hasSRV := s.srv[name]
+	addrs, hasAddrs := s.addrs[name]
+	switch qtype {
+	case dnsTypeSRV:
+		for _, r := range srv {
+			rdata := make([]byte, 6)
+			binary.BigEndian.PutUint16(rdata[0:], r.Priority)
+			binary.BigEndian.PutUint16(rdata[2:], r.Weight)
+			binary.BigEndian.PutUint16(rdata[4:], r.Port)
+			answers = append(answers, append(rdata, packDNSName(r.Target)...))
+		}
+	case dnsTypeA, dnsTypeAAAA:
+		for _, ip := range addrs {
+			if ip4 := ip.To4(); ip4 != nil && qtype == dnsTypeA {
+				answers = append(answers, ip4)
+			} else if ip4 == nil && qtype == dnsTypeAAAA {
+				answers = append(answers, ip.To16())
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	header := make([]byte, 12)
+	copy(header, query[:2])
+	flags := uint16(0x8580)
+	if !hasSRV && !hasAddrs {
+		// NXDOMAIN
+		flags |= 3
+	}
+	binary.BigEndian.PutUint16(header[2:], flags)
+	binary.BigEndian.PutUint16(header[4:], 1)
+	binary.BigEndian.PutUint16(header[6:], uint16(len(answers)))
+
+	resp := append(header, query[12:end]...)
+	for _, rdata := range answers {
+		rr := make([]byte, 12)
+		// the name is a pointer to the question
+		binary.BigEndian.PutUint16(rr[0:], 0xc00c)
+		binary.BigEndian.PutUint16(rr[2:], qtype)
+		binary.BigEndian.PutUint16(rr[4:], 1)
+		binary.BigEndian.PutUint32(rr[6:], 60)
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(rdata)))
+		resp = append(append(resp, rr...), rdata...)
+	}
+	return resp
+}
+
+func packDNSName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+func TestParsingDiscoverySources(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		value string
+		exp   Discovery
+	}{
+		{"dns-srv:_status._tcp.myapp.internal", Discovery{Kind: DiscoverSRV, Name: "_status._tcp.myapp.internal"}},
+		{"DNS-SRV:_status._tcp.myapp.internal", Discovery{Kind: DiscoverSRV, Name: "_status._tcp.myapp.internal"}},
+		{"dns:myapp.internal", Discovery{Kind: DiscoverDNS, Name: "myapp.internal"}},
+		{"dns:myapp.internal:8080", Discovery{Kind: DiscoverDNS, Name: "myapp.internal", Port: 8080}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseDiscovery(tt.value)
+			assert.Nil(err)
+			assert.Equal(tt.exp, d)
+		})
+	}
+
+	for _, v := range []string{"myapp.internal", "dns:", "dns-srv: ", "consul:myapp", "dns:myapp.internal:0", "dns:myapp.internal:http"} {
+		_, err := ParseDiscovery(v)
+		assert.NotNil(err, v)
+	}
+}
+
+func TestDiscoveringHostsFromDNS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dns := newTestDNSServer(t)
+	defer dns.Close()
+	dns.SetSRV("_status._tcp.myapp.internal",
+		net.SRV{Target: "web-2.myapp.internal.", Port: 8080, Priority: 10, Weight: 5},
+		net.SRV{Target: "web-1.myapp.internal.", Port: 8081, Priority: 10, Weight: 5},
+	)
+	dns.SetAddrs("myapp.internal", "10.2.0.7", "fd00::7")
+
+	resolver := NewResolver(dns.Addr())
+	ctx := context.Background()
+
+	hosts, err := Discovery{Kind: DiscoverSRV, Name: "_status._tcp.myapp.internal"}.Hosts(ctx, resolver)
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "web-1.myapp.internal", Port: 8081, Source: "dns-srv:_status._tcp.myapp.internal"},
+		{Name: "web-2.myapp.internal", Port: 8080, Source: "dns-srv:_status._tcp.myapp.internal"},
+	}, hosts)
+
+	hosts, err = Discovery{Kind: DiscoverDNS, Name: "myapp.internal", Port: 9000}.Hosts(ctx, resolver)
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "10.2.0.7", Port: 9000, Source: "dns:myapp.internal:9000"},
+		{Name: "fd00::7", Port: 9000, Source: "dns:myapp.internal:9000"},
+	}, hosts)
+	url, err := hosts[1].StatusURL("http://root.com")
+	assert.Nil(err)
+	assert.Equal("http://[fd00::7]:9000/status", url)
+
+	_, err = Discovery{Kind: DiscoverSRV, Name: "_status._tcp.missing.internal"}.Hosts(ctx, resolver)
+	assert.NotNil(err)
+}
+
+func TestDiscoveringSRVTargetsOnSeveralPorts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dns := newTestDNSServer(t)
+	defer dns.Close()
+	dns.SetSRV("_status._tcp.myapp.internal",
+		net.SRV{Target: "web-1.myapp.internal.", Port: 8081, Priority: 10, Weight: 5},
+		net.SRV{Target: "web-1.myapp.internal.", Port: 8080, Priority: 10, Weight: 5},
+		net.SRV{Target: "web-1.myapp.internal.", Port: 8080, Priority: 20, Weight: 5},
+	)
+	expected := []Host{
+		{Name: "web-1.myapp.internal", Port: 8080, Source: "dns-srv:_status._tcp.myapp.internal"},
+		{Name: "web-1.myapp.internal", Port: 8081, Source: "dns-srv:_status._tcp.myapp.internal"},
+	}
+
+	d := Discovery{Kind: DiscoverSRV, Name: "_status._tcp.myapp.internal"}
+	hosts, err := d.Hosts(context.Background(), NewResolver(dns.Addr()))
+	assert.Nil(err)
+	assert.Equal(expected, hosts)
+
+	inv := &Inventory{Discover: []Discovery{d}, Resolver: NewResolver(dns.Addr())}
+	hosts, err = inv.Load()
+	assert.Nil(err)
+	assert.Equal(expected, hosts)
+}
+
+func TestInventoryResolvesDiscoveryOnEveryLoad(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dns := newTestDNSServer(t)
+	defer dns.Close()
+	dns.SetSRV("_status._tcp.myapp.internal", net.SRV{Target: "web-1.myapp.internal.", Port: 8080})
+
+	inv, err := NewFlagInventory(Flag{Discover: []string{"dns-srv:_status._tcp.myapp.internal"}, Resolver: dns.Addr()})
+	assert.Nil(err)
+	inv.Paths = []string{"-"}
+	inv.Stdin = strings.NewReader("- {name: web-1.myapp.internal, port: 8080}\n- {name: web-0.myapp.internal, port: 8080}\n")
+
+	hosts, err := inv.Load()
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "web-1.myapp.internal", Port: 8080, Source: "stdin"},
+		{Name: "web-0.myapp.internal", Port: 8080, Source: "stdin"},
+	}, hosts)
+
+	dns.SetSRV("_status._tcp.myapp.internal",
+		net.SRV{Target: "web-2.myapp.internal.", Port: 8080},
+		net.SRV{Target: "web-3.myapp.internal.", Port: 8080},
+	)
+	hosts, err = inv.Load()
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "web-1.myapp.internal", Port: 8080, Source: "stdin"},
+		{Name: "web-0.myapp.internal", Port: 8080, Source: "stdin"},
+		{Name: "web-2.myapp.internal", Port: 8080, Source: "dns-srv:_status._tcp.myapp.internal"},
+		{Name: "web-3.myapp.internal", Port: 8080, Source: "dns-srv:_status._tcp.myapp.internal"},
+	}, hosts)
+
+	dns.Remove("_status._tcp.myapp.internal")
+	_, err = inv.Load()
+	assert.NotNil(err)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrUnmarshal    = errors.New("unable to unmarshal status response body")
+	ErrHTTPStatus   = errors.New("unexpected http status")
+	ErrTimeout      = errors.New("request timed out")
+	ErrDNS          = errors.New("unable to resolve host")
+	ErrConnRefused  = errors.New("connection refused")
+	ErrTLS          = errors.New("tls failure")
+	ErrBodyTooLarge = errors.New("status response body too large")
+	ErrEmptyStatus  = errors.New("status response does not name an application")
+	ErrInvalidURL   = errors.New("unable to create status URL")
+)
+
+// errorCategories maps each error kind to the category name it is reported under, in the
+// order in which they are checked.
+var errorCategories = []struct {
+	kind     error
+	category string
+}{
+	{ErrHTTPStatus, "http_status"},
+	{ErrTimeout, "timeout"},
+	{ErrDNS, "dns"},
+	{ErrConnRefused, "conn_refused"},
+	{ErrTLS, "tls"},
+	{ErrBodyTooLarge, "body_too_large"},
+	{ErrUnmarshal, "unmarshal"},
+	{ErrEmptyStatus, "empty_status"},
+	{ErrInvalidURL, "invalid_url"},
+}
+
+// StatusError is returned when a host responds with a non-2xx HTTP status.  It matches
+// ErrHTTPStatus with errors.Is, and can be retrieved with errors.As to inspect the code.
+type StatusError struct {
+	// Code is the HTTP status code of the response.
+	Code int
+	// RetryAfter is the delay requested by the host through the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %d %s", ErrHTTPStatus, e.Code, http.StatusText(e.Code))
+}
+
+// Is reports whether target is ErrHTTPStatus.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrHTTPStatus
+}
+
+// kindError annotates err with one of the package error kinds, so that callers can test for
+// the kind with errors.Is while the original cause remains available through errors.Unwrap.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string        { return fmt.Sprintf("%s: %s", e.kind, e.err) }
+func (e *kindError) Unwrap() error        { return e.err }
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// withKind returns err annotated with kind.
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// classifyRequestError annotates an error returned while making a status request with the kind
+// of failure which caused it.  Errors which cannot be classified are returned as they are.
+func classifyRequestError(err error) error {
+	var (
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		unknownCAErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certErr      x509.CertificateInvalidError
+		recordErr    tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return withKind(ErrDNS, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return withKind(ErrConnRefused, err)
+	case errors.As(err, &unknownCAErr), errors.As(err, &hostnameErr), errors.As(err, &certErr), errors.As(err, &recordErr):
+		return withKind(ErrTLS, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return withKind(ErrTimeout, err)
+	}
+	return err
+}
+
+// ErrorCategory returns the name of the category err is reported under, or "other" when err is
+// not one of the package error kinds.
+func ErrorCategory(err error) string {
+	for _, c := range errorCategories {
+		if errors.Is(err, c.kind) {
+			return c.category
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNonSuccessStatusReturnsHTTPStatusError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		code int
+	}{
+		{http.StatusNotFound},
+		{http.StatusInternalServerError},
+		{http.StatusMovedPermanently},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.code), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.code)
+				_, err := fmt.Fprintf(w, `<html>error</html>`)
+				assert.Nil(err)
+			}))
+			defer ts.Close()
+
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+			host := Host{URL: ts.URL, Client: client}
+			_, err := host.RequestHostStatus(context.Background())
+			assert.True(errors.Is(err, ErrHTTPStatus))
+			assert.False(errors.Is(err, ErrUnmarshal))
+
+			var statusErr *StatusError
+			assert.True(errors.As(err, &statusErr))
+			assert.Equal(tt.code, statusErr.Code)
+			assert.Equal("http_status", ErrorCategory(err))
+		})
+	}
+}
+
+func TestLargeBodyReturnsBodyTooLargeError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"application": "%s"}`, strings.Repeat("a", 100))
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	host := Host{URL: ts.URL, MaxBodySize: 64}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.True(errors.Is(err, ErrBodyTooLarge))
+	assert.Equal("body_too_large", ErrorCategory(err))
+}
+
+func TestInvalidJsonMatchesErrUnmarshal(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"invalid}}`)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	host := Host{URL: ts.URL}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.True(errors.Is(err, ErrUnmarshal))
+	assert.Equal("unmarshal", ErrorCategory(err))
+}
+
+func TestClosedPortReturnsConnRefusedError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	host := Host{URL: url}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.True(errors.Is(err, ErrConnRefused))
+	assert.Equal("conn_refused", ErrorCategory(err))
+}
+
+func TestSlowHostReturnsTimeoutError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	host := Host{URL: ts.URL, Client: NewHTTPClient(ClientConfig{Timeout: 50 * time.Millisecond})}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.Equal("timeout", ErrorCategory(err))
+}
+
+func TestUntrustedCertificateReturnsTLSError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	host := Host{URL: ts.URL, Client: NewHTTPClient(ClientConfig{})}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.True(errors.Is(err, ErrTLS))
+	assert.Equal("tls", ErrorCategory(err))
+}
+
+func TestClassifyingDNSErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	err := classifyRequestError(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "host0"}})
+	assert.True(errors.Is(err, ErrDNS))
+	assert.Equal("dns", ErrorCategory(err))
+}
+
+func TestUnclassifiedErrorsAreCategorizedAsOther(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Equal("other", ErrorCategory(errors.New("something else")))
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"fmt"
 	"github.com/integrii/flaggy"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var (
 	defaultLogLevel = "warn"
 	defaultRootURL  = "http://storage.googleapis.com/revsreinterview/hosts"
+
+	defaultConcurrency = 50
 )
 
 // Flag defines command line flags given at runtime.
@@ -15,37 +21,276 @@ type Flag struct {
 	// Version is the application Version, as taken from the VERSION file.  Version is the exception
 	// that is defined a build time rather than runtime.
 	Version string
+	// Command is the subcommand given, or empty when statusrep is to poll once and report.
+	Command string
+	// Listen is the address the serve command listens on.
+	Listen string
+	// Interval is how often the serve command polls every host.
+	Interval time.Duration
+	// Watch is the interval on which to poll every host and redraw the table report.  Zero means poll once.
+	Watch time.Duration
+	// OutputFormat is the format the report is written in.
+	OutputFormat string
+	// Outputs lists additional report outputs, each a list of key=value settings starting with format.
+	Outputs []string
+	// Template is the path of a text/template the report is rendered with.
+	Template string
+	// Sort lists the keys report rows are ordered by.
+	Sort []string
+	// GroupBy lists the dimensions report rows are aggregated by.
+	GroupBy []string
+	// Select restricts the hosts polled, each selector given as KEY=VALUE or KEY!=VALUE.
+	Select []string
+	// CSVColumns selects the columns of the csv report.
+	CSVColumns []string
+	// CSVDelimiter separates fields of the csv report.
+	CSVDelimiter string
+	// NoHeader suppresses the header row of the csv report.
+	NoHeader bool
+	// Color determines whether the table report is coloured.  One of auto, always or never.
+	Color string
+	// WarnBelow is the success rate under which table and html rows are highlighted.
+	WarnBelow float64
 	// LogLevel determines at what level to write application logs.
 	LogLevel string
-	// HostsFile contains the list of hosts to query, one per line.
-	HostsFile string
+	// HostsFiles lists the inventories of hosts to query, where - is stdin.
+	HostsFiles []string
+	// Discover lists DNS discovery sources of hosts to query, each dns-srv:NAME or dns:NAME[:PORT].
+	Discover []string
+	// Resolver is the address of the DNS server discovery sources are resolved with.  Empty means
+	// the system resolver.
+	Resolver string
 	// RootURL is the base url for host status pages.
 	RootURL string
+	// Concurrency is the number of hosts polled at the same time.
+	Concurrency int
+	// MaxInflightPerRoot limits outstanding requests against a single root URL.  Zero means no limit.
+	MaxInflightPerRoot int
+	// ConnectTimeout limits how long connecting to a host may take.
+	ConnectTimeout time.Duration
+	// TLSTimeout limits how long a TLS handshake with a host may take.
+	TLSTimeout time.Duration
+	// Timeout limits the total time of a single status request.
+	Timeout time.Duration
+	// RunTimeout is a deadline for the whole run, after which all outstanding requests are cancelled.
+	// Zero means no deadline.
+	RunTimeout time.Duration
+	// MaxAttempts is the maximum number of status requests made to a single host.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry, doubled for each following retry.
+	RetryDelay time.Duration
+	// RetryMaxDelay caps the delay between two retries.
+	RetryMaxDelay time.Duration
+	// RetryBudget is the total number of retries allowed across all hosts in a run.  Zero means no limit.
+	RetryBudget int
+	// MaxBodySize is the largest status response body accepted, in bytes.
+	MaxBodySize int64
+	// MinSuccessRate is the lowest success rate allowed for any application version.
+	MinSuccessRate float64
+	// AppMinSuccessRates overrides MinSuccessRate for applications, each as NAME[@VERSION]=RATE.
+	AppMinSuccessRates []string
+	// MaxFailedHosts is the largest number of hosts allowed to fail.  Negative means no limit.
+	MaxFailedHosts int
+	// MaxFailedHostRatio is the largest fraction of hosts allowed to fail.  Negative means no limit.
+	MaxFailedHostRatio float64
+	// CheckWindow checks success rates since the previous poll rather than lifetime success rates.
+	CheckWindow bool
+	// StateFile is where the counters last reported by each host are kept between runs.
+	StateFile string
+	// StateDir is the directory the history of runs is kept in, along with the state file when
+	// none is given.
+	StateDir string
+
+	// HistoryCommand is the history subcommand given: list, trend or prune.
+	HistoryCommand string
+	// HistoryLimit is the number of most recent runs listed by history list.
+	HistoryLimit int
+	// HistoryApp is the application whose trend is shown by history trend.
+	HistoryApp string
+	// HistoryAppVersion restricts history trend to a single version of the application.
+	HistoryAppVersion string
+	// PruneMaxAge is the age beyond which history prune removes runs.
+	PruneMaxAge time.Duration
+	// PruneKeep is the number of most recent runs history prune keeps.
+	PruneKeep int
+
+	// DiffBefore is the path of the earlier JSON report compared by the diff command.
+	DiffBefore string
+	// DiffAfter is the path of the later JSON report compared by the diff command.
+	DiffAfter string
+	// DiffThreshold is the smallest change in success rate reported by the diff command.
+	DiffThreshold float64
+
+	// CanaryApp is the application compared by the canary command.
+	CanaryApp string
+	// CanaryBaseline is the version of the application the candidate is compared against.
+	CanaryBaseline string
+	// CanaryCandidate is the version of the application being judged.
+	CanaryCandidate string
+	// CanaryConfidence is the confidence needed to fail the candidate.
+	CanaryConfidence float64
+	// CanaryMinRequests is the number of requests each version needs before a verdict is reached.
+	CanaryMinRequests int
 }
 
 func (f *Flag) Parse() {
+	f.parse(os.Args[1:])
+}
+
+// parse parses args as the command line, with a fresh flaggy parser.  Flags whose default is not
+// their zero value are set before parsing, so that giving them as zero is kept.
+func (f *Flag) parse(args []string) {
+	flaggy.ResetParser()
+	f.MaxFailedHosts = -1
+	f.MaxFailedHostRatio = -1
+	f.DiffThreshold = defaultDiffThreshold
+	f.CanaryMinRequests = defaultCanaryMinRequests
+	f.WarnBelow = defaultWarnBelow
+
 	flaggy.SetVersion(f.Version)
 	flaggy.SetName("statusrep")
 	flaggy.SetDescription("Generate reports for hosts with a status endpoint.")
 
+	serveCmd := flaggy.NewSubcommand("serve")
+	serveCmd.Description = "Poll all hosts on an interval and serve the latest report over HTTP at /metrics, /report and /healthz."
+	f.defineServeFlags(serveCmd)
+	flaggy.AttachSubcommand(serveCmd, 1)
+
+	historyCmd := flaggy.NewSubcommand("history")
+	historyCmd.Description = "Show the runs kept in the history of the state directory."
+	historyCmds := f.defineHistoryCommands(historyCmd)
+	flaggy.AttachSubcommand(historyCmd, 1)
+
+	diffCmd := flaggy.NewSubcommand("diff")
+	diffCmd.Description = "Compare two JSON reports, exiting with 3 when the later report has regressed."
+	f.defineDiffFlags(diffCmd)
+	flaggy.AttachSubcommand(diffCmd, 1)
+
+	canaryCmd := flaggy.NewSubcommand("canary")
+	canaryCmd.Description = "Poll all hosts once and judge a candidate version of an application against a baseline version."
+	f.defineCanaryFlags(canaryCmd)
+	flaggy.AttachSubcommand(canaryCmd, 1)
+
 	f.defineAllFlags()
-	flaggy.Parse()
+	flaggy.ParseArgs(args)
+	if serveCmd.Used {
+		f.Command = serveCmd.Name
+	}
+	if diffCmd.Used {
+		f.Command = diffCmd.Name
+	}
+	if canaryCmd.Used {
+		f.Command = canaryCmd.Name
+	}
+	if historyCmd.Used {
+		f.Command = historyCmd.Name
+		f.HistoryCommand = "list"
+		for _, cmd := range historyCmds {
+			if cmd.Used {
+				f.HistoryCommand = cmd.Name
+			}
+		}
+	}
 	f.setDefaults()
 	f.enforceRequirements()
 }
 
 func (f *Flag) defineAllFlags() {
+	flaggy.Duration(
+		&f.Watch,
+		"w",
+		"watch",
+		"Poll every host on this interval, redrawing the table report with the changes since the previous poll until interrupted.  Only the table format is supported.",
+	)
+	flaggy.String(
+		&f.OutputFormat,
+		"o",
+		"output-format",
+		fmt.Sprintf("Format of the report written to stdout.  This should be one of %s. (default: %s)", strings.Join(outputFormats, ", "), defaultOutputFormat),
+	)
+	flaggy.StringSlice(
+		&f.Outputs,
+		"",
+		"output",
+		"Write the report to an output given as format=FORMAT[,path=FILE][,template=FILE].  Repeat to write several outputs in one run.  Without a path the report is written to stdout, which only one output may be.  Settings cannot contain commas.  Replaces --output-format when given.",
+	)
+	flaggy.String(
+		&f.Template,
+		"",
+		"template",
+		"Render the report with the Go text/template at this path.  Implies --output-format template.",
+	)
+	flaggy.StringSlice(
+		&f.Sort,
+		"s",
+		"sort",
+		fmt.Sprintf("Comma separated keys to order report rows by, each optionally suffixed with :asc or :desc.  Available keys are %s. (default: name,version)", strings.Join(sortFieldNames(), ", ")),
+	)
+	flaggy.StringSlice(
+		&f.GroupBy,
+		"g",
+		"group-by",
+		"Comma separated dimensions to aggregate report rows by: application, version, or a host attribute such as host, dc, env, team or a label. (default: application,version)",
+	)
+	flaggy.StringSlice(
+		&f.Select,
+		"",
+		"select",
+		"Comma separated host selectors, each KEY=VALUE or KEY!=VALUE on a host attribute such as dc, env, team or a label.  Only hosts matching every selector are polled.",
+	)
+	flaggy.StringSlice(
+		&f.CSVColumns,
+		"",
+		"csv-columns",
+		fmt.Sprintf("Comma separated columns of the csv report, in order.  Available columns are %s. (default: all)", strings.Join(csvColumnNames(), ", ")),
+	)
+	flaggy.String(
+		&f.CSVDelimiter,
+		"",
+		"csv-delimiter",
+		"Field delimiter of the csv report.  Either a single character or one of comma, tab, semicolon, pipe. (default: comma)",
+	)
+	flaggy.Bool(
+		&f.NoHeader,
+		"",
+		"no-header",
+		"Do not write a header row in the csv report.",
+	)
+	flaggy.String(
+		&f.Color,
+		"",
+		"color",
+		fmt.Sprintf("Colour rows of the table report whose success rate is below --warn-below.  This should be one of auto, always, never. (default: %s)", defaultColorMode),
+	)
+	flaggy.Float64(
+		&f.WarnBelow,
+		"",
+		"warn-below",
+		"Success rate, between 0 and 1, under which table and html rows are highlighted.  Zero turns highlighting off.",
+	)
 	flaggy.String(
 		&f.LogLevel,
 		"l",
 		"log-level",
 		fmt.Sprintf("Application log level. This should be one of debug, info, warn, error, fatal. (default: %s)", defaultLogLevel),
 	)
-	flaggy.String(
-		&f.HostsFile,
+	flaggy.StringSlice(
+		&f.HostsFiles,
 		"f",
 		"hosts-file",
-		"File containing the list of servers to query, one per line.",
+		"Inventory of servers to query: one host pattern per line, or YAML, JSON or CSV with per host attributes.  Repeat to merge inventories, and give - to read stdin.",
+	)
+	flaggy.StringSlice(
+		&f.Discover,
+		"",
+		"discover",
+		"Discover servers to query from DNS, resolved on every poll: dns-srv:NAME for the targets and ports of SRV records, or dns:NAME[:PORT] for the addresses of A and AAAA records.",
+	)
+	flaggy.String(
+		&f.Resolver,
+		"",
+		"resolver",
+		"Address of the DNS server to discover servers with, as HOST[:PORT]. (default: the system resolver)",
 	)
 	flaggy.String(
 		&f.RootURL,
@@ -53,19 +298,457 @@ func (f *Flag) defineAllFlags() {
 		"root-url",
 		fmt.Sprintf("The root URL where host paths can be found.  This URL will be prepended to all queries. (default: %s)", defaultRootURL),
 	)
+	flaggy.Int(
+		&f.Concurrency,
+		"c",
+		"concurrency",
+		fmt.Sprintf("Number of hosts to poll at the same time. (default: %d)", defaultConcurrency),
+	)
+	flaggy.Int(
+		&f.MaxInflightPerRoot,
+		"",
+		"max-inflight-per-root",
+		"Maximum outstanding requests against any single root URL.  Zero means no limit. (default: 0)",
+	)
+	flaggy.Duration(
+		&f.ConnectTimeout,
+		"",
+		"connect-timeout",
+		fmt.Sprintf("Maximum time to wait for a connection to a host. (default: %s)", defaultConnectTimeout),
+	)
+	flaggy.Duration(
+		&f.TLSTimeout,
+		"",
+		"tls-timeout",
+		fmt.Sprintf("Maximum time to wait for a TLS handshake with a host. (default: %s)", defaultTLSHandshakeTimeout),
+	)
+	flaggy.Duration(
+		&f.Timeout,
+		"t",
+		"timeout",
+		fmt.Sprintf("Maximum total time for a single status request. (default: %s)", defaultRequestTimeout),
+	)
+	flaggy.Duration(
+		&f.RunTimeout,
+		"",
+		"run-timeout",
+		"Deadline for the whole run.  Outstanding requests are cancelled when it passes.  Zero means no deadline. (default: 0)",
+	)
+	flaggy.Int(
+		&f.MaxAttempts,
+		"",
+		"max-attempts",
+		fmt.Sprintf("Maximum number of status requests made to a single host when transient errors occur. (default: %d)", defaultMaxAttempts),
+	)
+	flaggy.Duration(
+		&f.RetryDelay,
+		"",
+		"retry-delay",
+		fmt.Sprintf("Delay before the first retry, doubled for each following retry. (default: %s)", defaultRetryDelay),
+	)
+	flaggy.Duration(
+		&f.RetryMaxDelay,
+		"",
+		"retry-max-delay",
+		fmt.Sprintf("Maximum delay between two retries.  Hosts asking, with Retry-After, to wait longer are not retried. (default: %s)", defaultRetryMaxDelay),
+	)
+	flaggy.Int(
+		&f.RetryBudget,
+		"",
+		"retry-budget",
+		"Total number of retries allowed across all hosts in a run.  Zero means no limit. (default: 0)",
+	)
+	flaggy.Int64(
+		&f.MaxBodySize,
+		"",
+		"max-body-size",
+		fmt.Sprintf("Largest status response body accepted, in bytes. (default: %d)", defaultMaxBodySize),
+	)
+	flaggy.Float64(
+		&f.MinSuccessRate,
+		"",
+		"min-success-rate",
+		"Lowest success rate, between 0 and 1, allowed for any application version.  statusrep exits with 3 when a version is below it.",
+	)
+	flaggy.StringSlice(
+		&f.AppMinSuccessRates,
+		"",
+		"app-min-success-rate",
+		"Lowest success rate allowed for an application, given as NAME=RATE or NAME@VERSION=RATE.  Overrides --min-success-rate.  Repeat for several applications.",
+	)
+	flaggy.Int(
+		&f.MaxFailedHosts,
+		"",
+		"max-failed-hosts",
+		"Largest number of hosts allowed to fail before statusrep exits with 3.  Negative means no limit.",
+	)
+	flaggy.Float64(
+		&f.MaxFailedHostRatio,
+		"",
+		"max-failed-host-ratio",
+		"Largest fraction of hosts, between 0 and 1, allowed to fail before statusrep exits with 3.  Negative means no limit.",
+	)
+	flaggy.Bool(
+		&f.CheckWindow,
+		"",
+		"check-window",
+		"Check success rates over the interval since the previous run, where known, rather than lifetime success rates.",
+	)
+	flaggy.String(
+		&f.StateFile,
+		"",
+		"state-file",
+		"File keeping the counters last reported by each host, so that rates since the previous run are reported.",
+	)
+	flaggy.String(
+		&f.StateDir,
+		"",
+		"state-dir",
+		"Directory keeping the history of every run, along with the state file when --state-file is not given.",
+	)
+}
+
+func (f *Flag) defineDiffFlags(cmd *flaggy.Subcommand) {
+	cmd.AddPositionalValue(&f.DiffBefore, "before", 1, true, "JSON report to compare against.")
+	cmd.AddPositionalValue(&f.DiffAfter, "after", 2, true, "JSON report to compare.")
+	cmd.Float64(
+		&f.DiffThreshold,
+		"",
+		"threshold",
+		"Smallest change in success rate, between 0 and 1, which is reported.  Drops of at least this much are regressions.",
+	)
+}
+
+func (f *Flag) defineCanaryFlags(cmd *flaggy.Subcommand) {
+	cmd.String(
+		&f.CanaryApp,
+		"a",
+		"application",
+		"Name of the application.",
+	)
+	cmd.String(
+		&f.CanaryBaseline,
+		"",
+		"baseline",
+		"Version of the application the candidate is compared against.",
+	)
+	cmd.String(
+		&f.CanaryCandidate,
+		"",
+		"candidate",
+		"Version of the application being judged.",
+	)
+	cmd.Float64(
+		&f.CanaryConfidence,
+		"",
+		"confidence",
+		fmt.Sprintf("Confidence, between 0 and 1, with which the candidate must succeed less often than the baseline to fail. (default: %v)", defaultCanaryConfidence),
+	)
+	cmd.Int(
+		&f.CanaryMinRequests,
+		"",
+		"min-requests",
+		"Requests each version needs before a verdict is reached.",
+	)
+}
+
+func (f *Flag) defineHistoryCommands(cmd *flaggy.Subcommand) []*flaggy.Subcommand {
+	listCmd := flaggy.NewSubcommand("list")
+	listCmd.Description = "List the most recent runs.  This is the default."
+	listCmd.Int(
+		&f.HistoryLimit,
+		"n",
+		"limit",
+		fmt.Sprintf("Number of most recent runs to list. (default: %d)", defaultHistoryLimit),
+	)
+	cmd.AttachSubcommand(listCmd, 1)
+
+	trendCmd := flaggy.NewSubcommand("trend")
+	trendCmd.Description = "Show the metrics of an application in every run."
+	trendCmd.String(
+		&f.HistoryApp,
+		"a",
+		"application",
+		"Name of the application.",
+	)
+	trendCmd.String(
+		&f.HistoryAppVersion,
+		"",
+		"app-version",
+		"Version of the application.  Every version is shown when empty.",
+	)
+	cmd.AttachSubcommand(trendCmd, 1)
+
+	pruneCmd := flaggy.NewSubcommand("prune")
+	pruneCmd.Description = "Remove old runs from the history."
+	pruneCmd.Duration(
+		&f.PruneMaxAge,
+		"",
+		"max-age",
+		"Remove runs which started longer ago than this.",
+	)
+	pruneCmd.Int(
+		&f.PruneKeep,
+		"",
+		"keep",
+		"Remove all but this many of the most recent runs.",
+	)
+	cmd.AttachSubcommand(pruneCmd, 1)
+
+	return []*flaggy.Subcommand{listCmd, trendCmd, pruneCmd}
+}
+
+func (f *Flag) defineServeFlags(cmd *flaggy.Subcommand) {
+	cmd.String(
+		&f.Listen,
+		"",
+		"listen",
+		fmt.Sprintf("Address to serve /metrics, /report and /healthz on. (default: %s)", defaultListenAddr),
+	)
+	cmd.Duration(
+		&f.Interval,
+		"i",
+		"interval",
+		fmt.Sprintf("How often to poll every host. (default: %s)", defaultPollInterval),
+	)
 }
 
 func (f *Flag) setDefaults() {
+	if f.Listen == "" {
+		f.Listen = defaultListenAddr
+	}
+	if f.Interval == 0 {
+		f.Interval = defaultPollInterval
+	}
+	if f.Template != "" && f.OutputFormat == "" {
+		f.OutputFormat = "template"
+	}
+	if f.OutputFormat == "" && (f.Command == "diff" || f.Command == "canary" || f.Watch > 0) {
+		f.OutputFormat = "table"
+	}
+	if f.OutputFormat == "" {
+		f.OutputFormat = defaultOutputFormat
+	}
+	if f.CanaryConfidence == 0 {
+		f.CanaryConfidence = defaultCanaryConfidence
+	}
+	if f.Color == "" {
+		f.Color = defaultColorMode
+	}
 	if f.LogLevel == "" {
 		f.LogLevel = defaultLogLevel
 	}
 	if f.RootURL == "" {
 		f.RootURL = defaultRootURL
 	}
+	if f.Concurrency == 0 {
+		f.Concurrency = defaultConcurrency
+	}
+	if f.ConnectTimeout == 0 {
+		f.ConnectTimeout = defaultConnectTimeout
+	}
+	if f.TLSTimeout == 0 {
+		f.TLSTimeout = defaultTLSHandshakeTimeout
+	}
+	if f.Timeout == 0 {
+		f.Timeout = defaultRequestTimeout
+	}
+	if f.MaxAttempts == 0 {
+		f.MaxAttempts = defaultMaxAttempts
+	}
+	if f.RetryDelay == 0 {
+		f.RetryDelay = defaultRetryDelay
+	}
+	if f.RetryMaxDelay == 0 {
+		f.RetryMaxDelay = defaultRetryMaxDelay
+	}
+	if f.MaxBodySize == 0 {
+		f.MaxBodySize = defaultMaxBodySize
+	}
+	if f.StateFile == "" && f.StateDir != "" {
+		f.StateFile = filepath.Join(f.StateDir, samplesFileName)
+	}
+	if f.HistoryLimit == 0 {
+		f.HistoryLimit = defaultHistoryLimit
+	}
+}
+
+// enforceWatchRequirements rejects flags which --watch would otherwise silently ignore, as watch
+// mode only ever draws the table report to stdout.
+func (f *Flag) enforceWatchRequirements() {
+	if f.Command != "" {
+		flaggy.ShowHelpAndExit("--watch cannot be used with the " + f.Command + " command.")
+	}
+	if !strings.EqualFold(f.OutputFormat, "table") {
+		flaggy.ShowHelpAndExit(fmt.Sprintf("--watch only draws the table report, and cannot be used with the %s output format.", f.OutputFormat))
+	}
+	if len(f.Outputs) > 0 {
+		flaggy.ShowHelpAndExit("--watch only draws the table report to stdout, and cannot be used with --output.")
+	}
+	if thresholds, err := f.Thresholds(); err == nil && (thresholds.Enabled() || thresholds.Window) {
+		flaggy.ShowHelpAndExit("--watch does not check thresholds, and cannot be used with --min-success-rate, --app-min-success-rate, --max-failed-hosts, --max-failed-host-ratio or --check-window.")
+	}
 }
 
 func (f *Flag) enforceRequirements() {
-	if f.HostsFile == "" {
-		flaggy.ShowHelpAndExit("hosts file is required.")
+	if f.Watch > 0 {
+		f.enforceWatchRequirements()
+	}
+	if f.Command == "history" {
+		f.enforceHistoryRequirements()
+		return
+	}
+	if f.Command == "diff" {
+		f.enforceDiffRequirements()
+		return
+	}
+	if len(f.HostsFiles) == 0 && len(f.Discover) == 0 {
+		flaggy.ShowHelpAndExit("hosts file or discovery source is required.")
+	}
+	if _, err := ParseDiscoveries(f.Discover); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	if f.Command == "canary" {
+		f.enforceCanaryRequirements()
+		return
+	}
+	if !validOutputFormat(f.OutputFormat) {
+		flaggy.ShowHelpAndExit(fmt.Sprintf("unknown output format '%s'.", f.OutputFormat))
+	}
+	if strings.EqualFold(f.OutputFormat, "template") && f.Template == "" {
+		flaggy.ShowHelpAndExit("a template file is required for the template output format.")
+	}
+	if f.Template != "" && !strings.EqualFold(f.OutputFormat, "template") {
+		flaggy.ShowHelpAndExit("--template can only be used with the template output format.")
+	}
+	specs, err := ParseOutputSpecs(f.Outputs)
+	if err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	for _, spec := range specs {
+		if strings.EqualFold(spec.Format, "template") && spec.Template == "" && f.Template == "" {
+			flaggy.ShowHelpAndExit("a template file is required for template outputs.")
+		}
+	}
+	if _, err := ParseSortKeys(f.Sort); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	groupBy, err := ParseGroupBy(f.GroupBy)
+	if err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	prometheus := f.Command == "serve" || strings.EqualFold(f.OutputFormat, "prometheus")
+	for _, spec := range specs {
+		prometheus = prometheus || strings.EqualFold(spec.Format, "prometheus")
+	}
+	if prometheus {
+		if _, err := promLabelNames((&Report{GroupBy: groupBy}).Dimensions()); err != nil {
+			flaggy.ShowHelpAndExit(err.Error() + ".")
+		}
+	}
+	if _, err := ParseSelectors(f.Select); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	if _, err := selectCSVColumns(f.CSVColumns); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	if _, err := ParseCSVDelimiter(f.CSVDelimiter); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	if _, err := ColorEnabled(f.Color, nil); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+	if f.WarnBelow < 0 || f.WarnBelow > 1 {
+		flaggy.ShowHelpAndExit("warn below must be between 0 and 1.")
+	}
+	if f.Concurrency < 0 {
+		flaggy.ShowHelpAndExit("concurrency must be a positive number.")
+	}
+	if f.MaxInflightPerRoot < 0 {
+		flaggy.ShowHelpAndExit("max inflight per root must not be negative.")
+	}
+	if f.ConnectTimeout < 0 || f.TLSTimeout < 0 || f.Timeout < 0 || f.RunTimeout < 0 {
+		flaggy.ShowHelpAndExit("timeouts must not be negative.")
+	}
+	if f.MaxAttempts < 0 || f.RetryBudget < 0 || f.RetryDelay < 0 || f.RetryMaxDelay < 0 {
+		flaggy.ShowHelpAndExit("retry settings must not be negative.")
+	}
+	if f.Interval < 0 || f.Watch < 0 {
+		flaggy.ShowHelpAndExit("intervals must be positive.")
+	}
+	if f.MaxBodySize < 0 {
+		flaggy.ShowHelpAndExit("max body size must not be negative.")
+	}
+	if f.MinSuccessRate < 0 || f.MinSuccessRate > 1 || f.MaxFailedHostRatio > 1 {
+		flaggy.ShowHelpAndExit("success rates and host ratios must be between 0 and 1.")
+	}
+	if _, err := f.Thresholds(); err != nil {
+		flaggy.ShowHelpAndExit(err.Error() + ".")
+	}
+}
+
+func (f *Flag) enforceCanaryRequirements() {
+	if f.CanaryApp == "" || f.CanaryBaseline == "" || f.CanaryCandidate == "" {
+		flaggy.ShowHelpAndExit("--application, --baseline and --candidate are required for the canary command.")
+	}
+	if f.CanaryBaseline == f.CanaryCandidate {
+		flaggy.ShowHelpAndExit("the baseline and candidate versions must differ.")
+	}
+	if !strings.EqualFold(f.OutputFormat, "table") && !strings.EqualFold(f.OutputFormat, "json") {
+		flaggy.ShowHelpAndExit("the canary command writes either the table or json output format.")
+	}
+	if f.CanaryConfidence <= 0 || f.CanaryConfidence >= 1 {
+		flaggy.ShowHelpAndExit("confidence must be between 0 and 1.")
+	}
+	if f.CanaryMinRequests < 0 {
+		flaggy.ShowHelpAndExit("min requests must not be negative.")
+	}
+}
+
+func (f *Flag) enforceDiffRequirements() {
+	if !strings.EqualFold(f.OutputFormat, "table") && !strings.EqualFold(f.OutputFormat, "json") {
+		flaggy.ShowHelpAndExit("the diff command writes either the table or json output format.")
+	}
+	if f.DiffThreshold < 0 || f.DiffThreshold > 1 {
+		flaggy.ShowHelpAndExit("threshold must be between 0 and 1.")
+	}
+}
+
+func (f *Flag) enforceHistoryRequirements() {
+	if f.StateDir == "" {
+		flaggy.ShowHelpAndExit("--state-dir is required for the history command.")
+	}
+	if f.HistoryCommand == "trend" && f.HistoryApp == "" {
+		flaggy.ShowHelpAndExit("--application is required for the history trend command.")
+	}
+	if f.HistoryCommand == "prune" && f.PruneMaxAge <= 0 && f.PruneKeep <= 0 {
+		flaggy.ShowHelpAndExit("--max-age or --keep is required for the history prune command.")
+	}
+	if f.HistoryLimit < 0 || f.PruneMaxAge < 0 || f.PruneKeep < 0 {
+		flaggy.ShowHelpAndExit("history limits must not be negative.")
+	}
+}
+
+// Thresholds returns the limits the report must stay within.
+func (f *Flag) Thresholds() (Thresholds, error) {
+	rates, err := ParseAppMinSuccessRates(f.AppMinSuccessRates)
+	if err != nil {
+		return Thresholds{}, err
+	}
+	return Thresholds{
+		MinSuccessRate:     f.MinSuccessRate,
+		AppMinSuccessRates: rates,
+		MaxFailedHosts:     f.MaxFailedHosts,
+		MaxFailedHostRatio: f.MaxFailedHostRatio,
+		Window:             f.CheckWindow,
+	}, nil
+}
+
+// OutputSpecs returns the outputs the report is written to.  Without any --output flags, the
+// report is written to stdout in the format given by --output-format.
+func (f *Flag) OutputSpecs() ([]OutputSpec, error) {
+	if len(f.Outputs) == 0 {
+		return []OutputSpec{{Format: f.OutputFormat, Template: f.Template}}, nil
 	}
+	return ParseOutputSpecs(f.Outputs)
 }
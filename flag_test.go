@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// parseTestFlags parses args as the command line.  Flags are parsed by the global flaggy parser,
+// so tests parsing flags do not run in parallel.
+func parseTestFlags(args ...string) Flag {
+	var f Flag
+	f.parse(args)
+	return f
+}
+
+func TestParsingFlagsKeepsExplicitZeros(t *testing.T) {
+	assert := assert.New(t)
+
+	f := parseTestFlags("-f", "hosts.txt")
+	assert.Equal(defaultWarnBelow, f.WarnBelow)
+	f = parseTestFlags("-f", "hosts.txt", "--warn-below", "0")
+	assert.Equal(float64(0), f.WarnBelow)
+
+	canary := []string{"canary", "--application", "app1", "--baseline", "v1", "--candidate", "v2", "-f", "hosts.txt"}
+	f = parseTestFlags(canary...)
+	assert.Equal(defaultCanaryMinRequests, f.CanaryMinRequests)
+	f = parseTestFlags(append(canary, "--min-requests", "0")...)
+	assert.Equal(0, f.CanaryMinRequests)
+
+	f = parseTestFlags("diff", "before.json", "after.json")
+	assert.Equal(defaultDiffThreshold, f.DiffThreshold)
+	f = parseTestFlags("diff", "before.json", "after.json", "--threshold", "0")
+	assert.Equal(float64(0), f.DiffThreshold)
+}
+
+func TestParsingWatchFlagsWithoutThresholds(t *testing.T) {
+	assert := assert.New(t)
+
+	f := parseTestFlags("-f", "hosts.txt", "--watch", "5s")
+	assert.Equal("table", f.OutputFormat)
+	thresholds, err := f.Thresholds()
+	assert.Nil(err)
+	assert.False(thresholds.Enabled())
+}
@@ -1,11 +1,12 @@
 module github.com/swtch1/statusrep
 
-go 1.12
+go 1.13
 
 require (
 	github.com/integrii/flaggy v1.2.2
-	github.com/pkg/errors v0.8.0
+	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// groupSeparator joins the values of group dimensions within a groupKey.
+const groupSeparator = "\x00"
+
+// Dimension is the value of a single dimension a report row is grouped by.
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// groupKey identifies a report row when rows are grouped by more than application and version.
+type groupKey struct {
+	App Application
+	// Dims holds the value of every other dimension, in order, joined by groupSeparator.
+	Dims string
+}
+
+// ParseGroupBy parses the dimensions to group report rows by.  The application and version
+// dimensions group by the application version each host reports, and any other dimension by
+// the host attribute of that name, such as dc, env, team or a label.
+func ParseGroupBy(values []string) ([]string, error) {
+	var dims []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		d := strings.ToLower(strings.TrimSpace(v))
+		switch d {
+		case "":
+			return nil, errors.New("group by dimensions must not be empty")
+		case "app", "name":
+			d = "application"
+		}
+		if seen[d] {
+			return nil, errors.Errorf("group by dimension '%s' is given more than once", d)
+		}
+		seen[d] = true
+		dims = append(dims, d)
+	}
+	return dims, nil
+}
+
+// Selector restricts the hosts polled to those whose attribute Key equals Value, or with
+// Negate, to those whose attribute does not.
+type Selector struct {
+	Key    string
+	Value  string
+	Negate bool
+}
+
+// ParseSelectors parses host selectors given as KEY=VALUE or KEY!=VALUE.
+func ParseSelectors(values []string) ([]Selector, error) {
+	var selectors []Selector
+	for _, v := range values {
+		var s Selector
+		i := strings.Index(v, "=")
+		if i < 0 {
+			return nil, errors.Errorf("selector '%s' must be given as KEY=VALUE or KEY!=VALUE", v)
+		}
+		s.Key, s.Value = v[:i], strings.TrimSpace(v[i+1:])
+		if strings.HasSuffix(s.Key, "!") {
+			s.Key, s.Negate = strings.TrimSuffix(s.Key, "!"), true
+		}
+		s.Key = strings.ToLower(strings.TrimSpace(s.Key))
+		if s.Key == "" {
+			return nil, errors.Errorf("selector '%s' does not name a host attribute", v)
+		}
+		selectors = append(selectors, s)
+	}
+	return selectors, nil
+}
+
+// Matches returns whether h is selected.
+func (s Selector) Matches(h Host) bool {
+	return (h.Attribute(s.Key) == s.Value) != s.Negate
+}
+
+// SelectHosts returns the hosts matching every selector.
+func SelectHosts(hosts []Host, selectors []Selector) []Host {
+	if len(selectors) == 0 {
+		return hosts
+	}
+
+	var selected []Host
+	for _, h := range hosts {
+		matches := true
+		for _, s := range selectors {
+			if !s.Matches(h) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, h)
+		}
+	}
+	return selected
+}
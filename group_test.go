@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParsingGroupBy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dims, err := ParseGroupBy([]string{"App", " version", "DC", "cluster"})
+	assert.Nil(err)
+	assert.Equal([]string{"application", "version", "dc", "cluster"}, dims)
+
+	_, err = ParseGroupBy([]string{"dc", "dc"})
+	assert.NotNil(err)
+	_, err = ParseGroupBy([]string{"application", " "})
+	assert.NotNil(err)
+}
+
+func TestSelectingHosts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	hosts := []Host{
+		{Name: "host1", Datacenter: "eu-west", Environment: "prod"},
+		{Name: "host2", Datacenter: "us-east", Environment: "prod", Labels: map[string]string{"cluster": "a"}},
+		{Name: "host3", Datacenter: "us-east", Environment: "staging", Labels: map[string]string{"cluster": "a"}},
+	}
+	names := func(hosts []Host) []string {
+		var names []string
+		for _, h := range hosts {
+			names = append(names, h.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		selectors []string
+		expHosts  []string
+	}{
+		{nil, []string{"host1", "host2", "host3"}},
+		{[]string{"env=prod"}, []string{"host1", "host2"}},
+		{[]string{"env=prod", "dc!=eu-west"}, []string{"host2"}},
+		{[]string{"cluster=a"}, []string{"host2", "host3"}},
+		{[]string{"cluster!=a"}, []string{"host1"}},
+		{[]string{"host=host3"}, []string{"host3"}},
+		{[]string{"team="}, []string{"host1", "host2", "host3"}},
+		{[]string{"dc=ap-south"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.selectors, ","), func(t *testing.T) {
+			selectors, err := ParseSelectors(tt.selectors)
+			assert.Nil(err)
+			assert.Equal(tt.expHosts, names(SelectHosts(hosts, selectors)))
+		})
+	}
+
+	for _, v := range []string{"env", "=prod", "!=prod"} {
+		_, err := ParseSelectors([]string{v})
+		assert.NotNil(err, v)
+	}
+}
+
+func TestReportGroupsRowsByDimensions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.GroupBy = []string{"application", "dc"}
+	r.Add(Result{Host: Host{Name: "host1", Datacenter: "eu"}, Status: HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 10, SuccessCount: 10}})
+	r.Add(Result{Host: Host{Name: "host2", Datacenter: "eu"}, Status: HostStatus{Application: "app1", Version: "1.1.0", RequestsCount: 10, SuccessCount: 5}})
+	r.Add(Result{Host: Host{Name: "host3", Datacenter: "us"}, Status: HostStatus{Application: "app1", Version: "1.1.0", RequestsCount: 20, SuccessCount: 20}})
+
+	assert.Equal([]string{"dc"}, r.Dimensions())
+	assert.Len(r.Apps, 2)
+
+	rows := r.Rows()
+	if assert.Len(rows, 2) {
+		assert.Equal(AppRow{App: Application{Name: "app1"}, Group: []Dimension{{"dc", "eu"}}, Metric: Metric{TotalRequestsCount: 20, TotalSuccessCount: 15, HostCount: 2}}, rows[0])
+		assert.Equal(AppRow{App: Application{Name: "app1"}, Group: []Dimension{{"dc", "us"}}, Metric: Metric{TotalRequestsCount: 20, TotalSuccessCount: 20, HostCount: 1}}, rows[1])
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(renderTable(&buf, r, TableOptions{}))
+	assert.True(strings.HasPrefix(buf.String(), "APPLICATION  VERSION  DC  REQUESTS"), buf.String())
+
+	buf.Reset()
+	assert.Nil(renderCSV(&buf, r, CSVOptions{Columns: []string{"name", "success_rate"}}))
+	assert.Equal("dc,name,success_rate\neu,app1,0.7500\nus,app1,1.0000\n", buf.String())
+
+	doc := NewReportDocument(r)
+	assert.Equal([]string{"application", "dc"}, doc.Run.GroupBy)
+	assert.Equal(map[string]string{"dc": "eu"}, doc.Applications[0].Groups)
+
+	buf.Reset()
+	assert.Nil(renderPrometheus(&buf, r))
+	assert.Contains(buf.String(), `statusrep_app_requests{application="app1",version="",dc="us"} 20`)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	historyFileName = "history.jsonl"
+	samplesFileName = "samples.json"
+
+	defaultHistoryLimit = 20
+)
+
+// HistoryRecord is the aggregated outcome of a single run, as kept in the history store.
+type HistoryRecord struct {
+	StartedAt       time.Time             `json:"started_at"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Hosts           HostsDocument         `json:"hosts"`
+	Applications    []ApplicationDocument `json:"applications"`
+}
+
+// NewHistoryRecord creates the history record of r.
+func NewHistoryRecord(r *Report) HistoryRecord {
+	doc := NewReportDocument(r)
+	return HistoryRecord{
+		StartedAt:       doc.Run.StartedAt,
+		DurationSeconds: doc.Run.DurationSeconds,
+		Hosts:           doc.Hosts,
+		Applications:    doc.Applications,
+	}
+}
+
+// History is an append-only store of run records, kept as one JSON document per line.
+type History struct {
+	Path string
+}
+
+// NewHistory returns the history store kept in the state directory dir.
+func NewHistory(dir string) *History {
+	return &History{Path: filepath.Join(dir, historyFileName)}
+}
+
+// Append adds the record of r to the end of the store, creating the store when necessary.  The
+// store is locked while writing, so that a record is not lost to a prune running alongside.
+func (h *History) Append(r *Report) error {
+	line, err := json.Marshal(NewHistoryRecord(r))
+	if err != nil {
+		return errors.Wrap(err, "unable to encode history record")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.Path), 0755); err != nil {
+		return errors.Wrapf(err, "unable to create state directory '%s'", filepath.Dir(h.Path))
+	}
+	unlock, err := lockHistory(h.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(h.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open history '%s'", h.Path)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "unable to write to history '%s'", h.Path)
+	}
+	return errors.Wrapf(f.Close(), "unable to close history '%s'", h.Path)
+}
+
+// Records returns every record in the store, oldest first.  An empty store has no records.
+func (h *History) Records() ([]HistoryRecord, error) {
+	f, err := os.Open(h.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open history '%s'", h.Path)
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, errors.Wrapf(err, "unable to read line %d of history '%s'", n, h.Path)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "unable to read history '%s'", h.Path)
+	}
+	return records, nil
+}
+
+// Prune removes records which started before olderThan, and then all but the newest keep
+// records.  A zero olderThan or keep disables that limit.  The number of records removed is
+// returned.  The store is locked throughout, so that runs appending to it wait for the prune.
+func (h *History) Prune(olderThan time.Time, keep int) (int, error) {
+	if _, err := os.Stat(h.Path); os.IsNotExist(err) {
+		return 0, nil
+	}
+	unlock, err := lockHistory(h.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	records, err := h.Records()
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+
+	var kept []HistoryRecord
+	for _, rec := range records {
+		if olderThan.IsZero() || !rec.StartedAt.Before(olderThan) {
+			kept = append(kept, rec)
+		}
+	}
+	if keep > 0 && len(kept) > keep {
+		kept = kept[len(kept)-keep:]
+	}
+	if len(kept) == len(records) {
+		return 0, nil
+	}
+
+	err = writeFileAtomic(h.Path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, rec := range kept {
+			if err := enc.Encode(rec); err != nil {
+				return errors.Wrap(err, "unable to encode history record")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records) - len(kept), nil
+}
+
+// HistoryTrendPoint is the metrics of an application version in a single run.
+type HistoryTrendPoint struct {
+	StartedAt   time.Time
+	Application ApplicationDocument
+}
+
+// Trend returns the metrics of the application in every record, oldest first.  Only the given
+// version is included unless version is empty.
+func Trend(records []HistoryRecord, name, version string) []HistoryTrendPoint {
+	var points []HistoryTrendPoint
+	for _, rec := range records {
+		for _, app := range rec.Applications {
+			if app.Name == name && (version == "" || app.Version == version) {
+				points = append(points, HistoryTrendPoint{StartedAt: rec.StartedAt, Application: app})
+			}
+		}
+	}
+	return points
+}
+
+// saveHistory appends r to the history in the state directory, when one is given.
+func saveHistory(flag Flag, r *Report) {
+	if flag.StateDir == "" {
+		return
+	}
+	if err := NewHistory(flag.StateDir).Append(r); err != nil {
+		log.WithError(err).Error("unable to save run to history")
+	}
+}
+
+// runHistory lists, shows the trend of or prunes the runs in the history store.
+func runHistory(flag Flag) {
+	history := NewHistory(flag.StateDir)
+
+	if flag.HistoryCommand == "prune" {
+		var olderThan time.Time
+		if flag.PruneMaxAge > 0 {
+			olderThan = time.Now().Add(-flag.PruneMaxAge)
+		}
+		removed, err := history.Prune(olderThan, flag.PruneKeep)
+		if err != nil {
+			log.WithError(err).Fatal("unable to prune history")
+		}
+		fmt.Fprintf(os.Stderr, "removed %d runs from history\n", removed)
+		return
+	}
+
+	records, err := history.Records()
+	if err != nil {
+		log.WithError(err).Fatal("unable to read history")
+	}
+
+	switch flag.HistoryCommand {
+	case "trend":
+		err = writeHistoryTrend(os.Stdout, Trend(records, flag.HistoryApp, flag.HistoryAppVersion))
+	default:
+		if flag.HistoryLimit > 0 && len(records) > flag.HistoryLimit {
+			records = records[len(records)-flag.HistoryLimit:]
+		}
+		err = writeHistoryRuns(os.Stdout, records)
+	}
+	if err != nil {
+		log.WithError(err).Fatal("unable to write history")
+	}
+}
+
+// writeHistoryRuns writes a line summarizing every record.
+func writeHistoryRuns(w io.Writer, records []HistoryRecord) error {
+	columns := []tableColumn{{header: "STARTED"}, {header: "DURATION", alignRight: true}, {header: "HOSTS", alignRight: true}, {header: "FAILED", alignRight: true}, {header: "APPLICATIONS", alignRight: true}}
+	cells := make([][]string, len(records))
+	for i, rec := range records {
+		cells[i] = []string{
+			rec.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			time.Duration(rec.DurationSeconds * float64(time.Second)).Truncate(time.Millisecond).String(),
+			fmt.Sprintf("%s/%s", formatCount(uint(rec.Hosts.Reported)), formatCount(uint(rec.Hosts.Total))),
+			formatCount(uint(rec.Hosts.Failed)),
+			formatCount(uint(len(rec.Applications))),
+		}
+	}
+	return writeTableCells(w, columns, cells)
+}
+
+// writeHistoryTrend writes a line for every point of an application trend.
+func writeHistoryTrend(w io.Writer, points []HistoryTrendPoint) error {
+	columns := []tableColumn{{header: "STARTED"}, {header: "VERSION"}, {header: "HOSTS", alignRight: true}, {header: "REQUESTS", alignRight: true}, {header: "SUCCESS RATE", alignRight: true}, {header: "WINDOW RATE", alignRight: true}}
+	cells := make([][]string, len(points))
+	for i, p := range points {
+		window := "-"
+		if p.Application.Window != nil && p.Application.Window.Requests > 0 {
+			window = formatPercent(p.Application.Window.SuccessRate)
+		}
+		cells[i] = []string{
+			p.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			p.Application.Version,
+			formatCount(p.Application.Hosts),
+			formatCount(p.Application.Requests),
+			formatPercent(p.Application.SuccessRate),
+			window,
+		}
+	}
+	return writeTableCells(w, columns, cells)
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"syscall"
+)
+
+// lockHistory takes an exclusive advisory lock on the history store at path, waiting for any
+// other process holding it.  The lock is kept on a file beside the store, since pruning replaces
+// the store itself.  The returned function releases the lock.
+func lockHistory(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open history lock '%s.lock'", path)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "unable to lock history '%s'", path)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
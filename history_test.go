@@ -0,0 +1,116 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func historyTestReport(start time.Time, requests uint) *Report {
+	r := NewReport(make(map[Application]Metric))
+	r.Start = start
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: requests, SuccessCount: requests}})
+	r.Add(Result{Status: HostStatus{Application: "app2", Version: "2.0.0", RequestsCount: 5, SuccessCount: 4, ErrorCount: 1}})
+	return r
+}
+
+func TestHistoryAppendsAndPrunesRuns(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	history := NewHistory(dir)
+
+	records, err := history.Records()
+	assert.Nil(err)
+	assert.Empty(records)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		assert.Nil(history.Append(historyTestReport(start.Add(time.Duration(i)*time.Hour), uint(10*(i+1)))))
+	}
+
+	records, err = history.Records()
+	assert.Nil(err)
+	assert.Len(records, 5)
+	assert.Equal(start, records[0].StartedAt)
+	assert.Equal(2, records[0].Hosts.Reported)
+	assert.Len(records[0].Applications, 2)
+
+	removed, err := history.Prune(start.Add(90*time.Minute), 0)
+	assert.Nil(err)
+	assert.Equal(2, removed)
+
+	removed, err = history.Prune(time.Time{}, 2)
+	assert.Nil(err)
+	assert.Equal(1, removed)
+
+	records, err = history.Records()
+	assert.Nil(err)
+	assert.Len(records, 2)
+	assert.Equal(start.Add(3*time.Hour), records[0].StartedAt)
+
+	removed, err = history.Prune(time.Time{}, 2)
+	assert.Nil(err)
+	assert.Equal(0, removed)
+}
+
+func TestHistoryPruneDoesNotLoseConcurrentAppends(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	history := NewHistory(dir)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	const appends = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < appends; i++ {
+			assert.Nil(history.Append(historyTestReport(start, 10)))
+		}
+	}()
+
+	removed := 0
+	for pruning := true; pruning; {
+		select {
+		case <-done:
+			pruning = false
+		default:
+		}
+		n, err := history.Prune(start.Add(time.Hour), 0)
+		assert.Nil(err)
+		removed += n
+	}
+
+	records, err := history.Records()
+	assert.Nil(err)
+	assert.Equal(appends, removed+len(records))
+}
+
+func TestHistoryTrendOfApplication(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []HistoryRecord{
+		NewHistoryRecord(historyTestReport(start, 10)),
+		NewHistoryRecord(historyTestReport(start.Add(time.Hour), 20)),
+	}
+
+	points := Trend(records, "app1", "")
+	assert.Len(points, 2)
+	assert.Equal(start.Add(time.Hour), points[1].StartedAt)
+	assert.Equal(uint(20), points[1].Application.Requests)
+
+	assert.Len(Trend(records, "app2", "2.0.0"), 2)
+	assert.Empty(Trend(records, "app2", "1.0.0"))
+	assert.Empty(Trend(records, "app3", ""))
+}
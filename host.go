@@ -2,24 +2,50 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 )
 
-var (
-	ErrUnmarshal = errors.New("unable to unmarshal status response body")
-)
+var defaultMaxBodySize int64 = 1 << 20
 
 // Host is an external host, with a URL, which has a status endpoint which can be queried.
 type Host struct {
-	// URL is the full URL where a host status is queried.
+	// Name is the host as given in the hosts file.
+	Name string
+	// URL is the full URL where a host status is queried.  When empty it is created by StatusURL.
 	URL string
+	// Port and Scheme, when either is set, query the status endpoint of the host directly rather
+	// than through the root URL.
+	Port   int
+	Scheme string
+	// Datacenter, Environment and Team describe where the host runs and who owns it.
+	Datacenter  string
+	Environment string
+	Team        string
+	// Labels holds any other attributes of the host.
+	Labels map[string]string
+	// Source is the inventory the host was read from, and Line the line of the inventory naming
+	// the host when it is known.
+	Source string
+	Line   int
+
+	// Client makes the status request.  It is expected to be shared between hosts so that
+	// connections are reused.  http.DefaultClient is used when Client is nil.
+	Client *http.Client
+	// MaxBodySize is the largest status response body accepted, in bytes.  A default of 1MiB
+	// is used when MaxBodySize is zero.
+	MaxBodySize int64
 }
 
 // HostStatus contains status information for a single host, at the time of querying.
@@ -32,30 +58,56 @@ type HostStatus struct {
 }
 
 // RequestHostStatus gets the HostStatus by making an outbound request to the host status URL.
-func (h *Host) RequestHostStatus() (HostStatus, error) {
+// The request is abandoned if ctx is cancelled or its deadline passes.
+func (h *Host) RequestHostStatus(ctx context.Context) (HostStatus, error) {
 	var status HostStatus
-	b, err := h.getStatus()
+	b, err := h.getStatus(ctx)
 	if err != nil {
 		return status, err
 	}
 	if err := json.Unmarshal(b, &status); err != nil {
-		return status, errors.Wrapf(err, "%s: '%s'", ErrUnmarshal, h.URL)
+		return status, errors.Wrapf(withKind(ErrUnmarshal, err), "'%s'", h.URL)
 	}
 	return status, nil
 }
 
-func (h *Host) getStatus() ([]byte, error) {
-	resp, err := http.Get(h.URL)
+func (h *Host) getStatus(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, h.URL, nil)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to get status for '%s'", h.URL)
+		return nil, errors.Wrapf(err, "unable to create request for '%s'", h.URL)
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	resp, err := h.client().Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to read response body for '%s'", h.URL)
+		return nil, errors.Wrapf(classifyRequestError(err), "unable to get status for '%s'", h.URL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		return nil, errors.Wrapf(statusErr, "unable to get status for '%s'", h.URL)
+	}
+
+	maxSize := h.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = defaultMaxBodySize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(classifyRequestError(err), "unable to read response body for '%s'", h.URL)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, errors.Wrapf(ErrBodyTooLarge, "response body for '%s' exceeds %d bytes", h.URL, maxSize)
 	}
 	return body, nil
 }
 
+func (h *Host) client() *http.Client {
+	if h.Client == nil {
+		return http.DefaultClient
+	}
+	return h.Client
+}
+
 // ReadAllHosts returns a slice of all hosts from a newline delimited reader.
 // For example, the output from reading r will look like this:
 // host0
@@ -63,24 +115,160 @@ func (h *Host) getStatus() ([]byte, error) {
 // host2
 //
 // White space surrounding the hosts will be stripped and empty lines will be discarded.
+// Everything from a # to the end of a line is a comment.  Each line is a pattern, expanded
+// by ExpandHostPattern, and a line starting with ! excludes the hosts of its pattern from
+// those of every other line.  A host named on more than one line is kept only where it is first
+// named, with a warning.  An @include line is an error, since only a hosts file loaded by an
+// Inventory may hold one.
 func ReadAllHosts(r io.ReadCloser) ([]string, error) {
 	defer r.Close()
 	var hosts []string
 
+	lines, excluded, err := readHostsFile(r)
+	if err != nil {
+		return hosts, err
+	}
+	seen := make(map[string]int)
+	for _, l := range lines {
+		if l.Include != "" {
+			return nil, errors.Errorf("line %d: @include is only allowed in hosts files", l.Line)
+		}
+		if excluded[l.Host] {
+			continue
+		}
+		if prev, ok := seen[l.Host]; ok {
+			log.Warnf("line %d: host '%s' is already given on line %d", l.Line, l.Host, prev)
+			continue
+		}
+		seen[l.Host] = l.Line
+		hosts = append(hosts, l.Host)
+	}
+	return hosts, nil
+}
+
+// hostsFileLine is a host, or an included hosts file, named on a line of a hosts file.
+type hostsFileLine struct {
+	Host    string
+	Include string
+	Line    int
+}
+
+// readHostsFile reads the hosts and includes of a hosts file, in order, along with the hosts
+// its exclude lines name.  A host named on more than one line is returned for each of them.
+func readHostsFile(r io.Reader) ([]hostsFileLine, map[string]bool, error) {
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(r); err != nil {
-		return hosts, errors.Wrap(err, "reading hosts failed")
+		return nil, nil, errors.Wrap(err, "reading hosts failed")
 	}
 
-	for _, h := range strings.Split(buf.String(), "\n") {
+	var hosts []hostsFileLine
+	excluded := make(map[string]bool)
+	for n, h := range strings.Split(buf.String(), "\n") {
+		if i := strings.IndexByte(h, '#'); i >= 0 {
+			h = h[:i]
+		}
 		// don't allow space around hosts
 		trHost := strings.TrimSpace(h)
 		// don't add empty lines
-		if len(trHost) > 0 {
-			hosts = append(hosts, trHost)
+		if len(trHost) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(trHost, "@") {
+			fields := strings.Fields(trHost)
+			if fields[0] != "@include" {
+				return nil, nil, errors.Errorf("line %d: unknown directive '%s'", n+1, fields[0])
+			}
+			if len(fields) != 2 {
+				return nil, nil, errors.Errorf("line %d: @include takes a single path", n+1)
+			}
+			hosts = append(hosts, hostsFileLine{Include: fields[1], Line: n + 1})
+			continue
+		}
+
+		exclude := strings.HasPrefix(trHost, "!")
+		if exclude {
+			trHost = strings.TrimSpace(trHost[1:])
+			if trHost == "" {
+				return nil, nil, errors.Errorf("line %d: nothing to exclude", n+1)
+			}
+		}
+		expanded, err := ExpandHostPattern(trHost)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "line %d", n+1)
+		}
+		for _, host := range expanded {
+			if exclude {
+				excluded[host] = true
+				continue
+			}
+			hosts = append(hosts, hostsFileLine{Host: host, Line: n + 1})
 		}
 	}
-	return hosts, nil
+	return hosts, excluded, nil
+}
+
+// StatusURL returns the URL where the status of the host is queried.  The URL of the host is
+// used when set.  A host with a port or scheme is queried directly at /status, using http when
+// no scheme is given, and any other host is queried through the root URL as by HostStatusURL.
+func (h Host) StatusURL(rootURL string) (string, error) {
+	if h.URL != "" {
+		return h.URL, nil
+	}
+	if h.Port == 0 && h.Scheme == "" {
+		return HostStatusURL(rootURL, h.Name)
+	}
+
+	u := url.URL{Scheme: h.Scheme, Host: h.Name, Path: "/status"}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if h.Port != 0 {
+		u.Host = net.JoinHostPort(h.Name, strconv.Itoa(h.Port))
+	}
+	return u.String(), nil
+}
+
+// key identifies the host by its status URL relative to the root URL, so that the same name on
+// different ports or schemes names different hosts.
+func (h Host) key() string {
+	key, err := h.StatusURL("")
+	if err != nil {
+		return h.Name
+	}
+	return key
+}
+
+// Origin returns where the host was read from as SOURCE:LINE, or just the source when the line
+// is not known.
+func (h Host) Origin() string {
+	if h.Line == 0 {
+		return h.Source
+	}
+	return h.Source + ":" + strconv.Itoa(h.Line)
+}
+
+// Attribute returns the value of the named attribute of the host: host, dc, env, team, scheme,
+// port, or otherwise the label of that name.  Attributes which are not set are empty.
+func (h Host) Attribute(name string) string {
+	switch name {
+	case "host":
+		return h.Name
+	case "dc":
+		return h.Datacenter
+	case "env":
+		return h.Environment
+	case "team":
+		return h.Team
+	case "scheme":
+		return h.Scheme
+	case "port":
+		if h.Port == 0 {
+			return ""
+		}
+		return strconv.Itoa(h.Port)
+	}
+	return h.Labels[name]
 }
 
 // HostStatusURL adds a host and the status endpoint to the path of a root url,
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestInvalidJsonReturnsUnmarshalError(t *testing.T) {
@@ -23,10 +25,46 @@ func TestInvalidJsonReturnsUnmarshalError(t *testing.T) {
 	defer ts.Close()
 
 	host := Host{URL: ts.URL}
-	_, err := host.RequestHostStatus()
+	_, err := host.RequestHostStatus(context.Background())
 	assert.True(strings.Contains(err.Error(), ErrUnmarshal.Error()))
 }
 
+func TestRequestHostStatusHonorsClientTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	host := Host{URL: ts.URL, Client: NewHTTPClient(ClientConfig{Timeout: 50 * time.Millisecond})}
+	_, err := host.RequestHostStatus(context.Background())
+	assert.NotNil(err)
+}
+
+func TestRequestHostStatusHonorsContextCancellation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	host := Host{URL: ts.URL, Client: NewHTTPClient(ClientConfig{})}
+	_, err := host.RequestHostStatus(ctx)
+	assert.NotNil(err)
+	assert.Equal(context.DeadlineExceeded, ctx.Err())
+}
+
 func TestGettingHostStatusSetsRequestsCount(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -39,7 +77,7 @@ func TestGettingHostStatusSetsRequestsCount(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		t.Run(string(tt.expRequestsCount), func(t *testing.T) {
+		t.Run(fmt.Sprint(tt.expRequestsCount), func(t *testing.T) {
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Content-Type", "application/json")
 				_, err := fmt.Fprintf(w, `{"requests_count": %d}`, tt.expRequestsCount)
@@ -48,7 +86,7 @@ func TestGettingHostStatusSetsRequestsCount(t *testing.T) {
 			defer ts.Close()
 
 			host := Host{URL: ts.URL}
-			status, err := host.RequestHostStatus()
+			status, err := host.RequestHostStatus(context.Background())
 			assert.Nil(err)
 			assert.Equal(tt.expRequestsCount, status.RequestsCount)
 		})
@@ -76,7 +114,7 @@ func TestGettingHostStatusSetsApplication(t *testing.T) {
 			defer ts.Close()
 
 			host := Host{URL: ts.URL}
-			status, err := host.RequestHostStatus()
+			status, err := host.RequestHostStatus(context.Background())
 			assert.Nil(err)
 			assert.Equal(tt.expApplication, status.Application)
 		})
@@ -104,7 +142,7 @@ func TestGettingHostStatusSetsVersion(t *testing.T) {
 			defer ts.Close()
 
 			host := Host{URL: ts.URL}
-			status, err := host.RequestHostStatus()
+			status, err := host.RequestHostStatus(context.Background())
 			assert.Nil(err)
 			assert.Equal(tt.expVersion, status.Version)
 		})
@@ -123,7 +161,7 @@ func TestGettingHostStatusSetsErrorCount(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		t.Run(string(tt.expErrorCount), func(t *testing.T) {
+		t.Run(fmt.Sprint(tt.expErrorCount), func(t *testing.T) {
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Content-Type", "application/json")
 				_, err := fmt.Fprintf(w, `{"error_count": %d}`, tt.expErrorCount)
@@ -132,7 +170,7 @@ func TestGettingHostStatusSetsErrorCount(t *testing.T) {
 			defer ts.Close()
 
 			host := Host{URL: ts.URL}
-			status, err := host.RequestHostStatus()
+			status, err := host.RequestHostStatus(context.Background())
 			assert.Nil(err)
 			assert.Equal(tt.expErrorCount, status.ErrorCount)
 		})
@@ -151,7 +189,7 @@ func TestGettingHostStatusSetsSuccessCount(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		t.Run(string(tt.expSuccessCount), func(t *testing.T) {
+		t.Run(fmt.Sprint(tt.expSuccessCount), func(t *testing.T) {
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Content-Type", "application/json")
 				_, err := fmt.Fprintf(w, `{"success_count": %d}`, tt.expSuccessCount)
@@ -160,7 +198,7 @@ func TestGettingHostStatusSetsSuccessCount(t *testing.T) {
 			defer ts.Close()
 
 			host := Host{URL: ts.URL}
-			status, err := host.RequestHostStatus()
+			status, err := host.RequestHostStatus(context.Background())
 			assert.Nil(err)
 			assert.Equal(tt.expSuccessCount, status.SuccessCount)
 		})
@@ -205,6 +243,60 @@ func TestGettingHostsTrimsWhiteSpace(t *testing.T) {
 	}
 }
 
+func TestGettingHostsExpandsPatterns(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		hosts    string
+		expSlice []string
+	}{
+		{"comments", "# web servers\nhost1 # primary\n  # host2\nhost3", []string{"host1", "host3"}},
+		{"ranges", "web-[01-03].dc1\napi{a,b}", []string{"web-01.dc1", "web-02.dc1", "web-03.dc1", "apia", "apib"}},
+		{"cidr", "10.2.0.0/30", []string{"10.2.0.1", "10.2.0.2"}},
+		{"path", "team/host1\nteam/host2", []string{"team/host1", "team/host2"}},
+		{"exclude", "!web-02\nweb-[01-04]\n! web-04 # retired", []string{"web-01", "web-03"}},
+		{"exclude missing host", "web-1\n!web-2", []string{"web-1"}},
+		{"duplicate", "host1\nhost2\nhost1", []string{"host1", "host2"}},
+		{"overlapping ranges", "web-[1-3]\n\nweb-[3-4]", []string{"web-1", "web-2", "web-3", "web-4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := ReadAllHosts(TestReadCloser{data: []byte(tt.hosts)})
+			assert.Nil(err)
+			assert.Equal(tt.expSlice, hosts)
+		})
+	}
+}
+
+func TestGettingHostsRejectsInvalidLines(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name   string
+		hosts  string
+		expErr string
+	}{
+		{"empty exclude", "host1\n!", "line 2: nothing to exclude"},
+		{"invalid pattern", "host1\nweb-[1-", "line 2: unclosed '[' in 'web-[1-'"},
+		{"include", "host1\n@include more.txt", "line 2: @include is only allowed in hosts files"},
+		{"include without path", "@include", "line 1: @include takes a single path"},
+		{"unknown directive", "@exclude host1", "line 1: unknown directive '@exclude'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadAllHosts(TestReadCloser{data: []byte(tt.hosts)})
+			if assert.NotNil(err) {
+				assert.Equal(tt.expErr, err.Error())
+			}
+		})
+	}
+}
+
 func TestHostURLCreation(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -217,6 +309,7 @@ func TestHostURLCreation(t *testing.T) {
 		{"http://some.root.com", "host1", "http://some.root.com/host1/status"},
 		{"http://root.com", "host2", "http://root.com/host2/status"},
 		{"http://root.com:80", "host3", "http://root.com:80/host3/status"},
+		{"http://root.com", "team/host1", "http://root.com/team/host1/status"},
 	}
 
 	for _, tt := range tests {
@@ -227,3 +320,28 @@ func TestHostURLCreation(t *testing.T) {
 		})
 	}
 }
+
+func TestHostStatusURLOverrides(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name   string
+		host   Host
+		expURL string
+	}{
+		{"root url", Host{Name: "host1"}, "http://root.com/host1/status"},
+		{"url", Host{Name: "host1", URL: "https://host1.example.com/healthz"}, "https://host1.example.com/healthz"},
+		{"port", Host{Name: "host1", Port: 8080}, "http://host1:8080/status"},
+		{"scheme", Host{Name: "host1", Scheme: "https"}, "https://host1/status"},
+		{"scheme and port", Host{Name: "10.0.0.1", Scheme: "https", Port: 8443}, "https://10.0.0.1:8443/status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := tt.host.StatusURL("http://root.com")
+			assert.Nil(err)
+			assert.Equal(tt.expURL, url)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxExpandedHosts is the most hosts a single pattern may expand to, so that a mistyped range
+// cannot exhaust memory.
+const maxExpandedHosts = 1 << 20
+
+// ExpandHostPattern returns every host named by a pattern from the hosts file.  Numeric ranges
+// such as web-[001-250] count from their start to their end, keeping any zero padding of the
+// start, and alternations such as api{a,b,c} take each alternative in turn.  A pattern may hold
+// any number of ranges and alternations, and names every combination of them.  A CIDR block
+// such as 10.2.0.0/28 names every host address in the block, leaving out the network and
+// broadcast addresses of IPv4 blocks larger than /31.  A pattern holding a / which is not a CIDR
+// block, such as team/host1, is expanded like any other, unless it starts with an IP address.
+// Any other pattern names just itself.
+func ExpandHostPattern(pattern string) ([]string, error) {
+	if _, _, err := net.ParseCIDR(pattern); err == nil {
+		return expandCIDR(pattern)
+	}
+	if i := strings.IndexByte(pattern, '/'); i >= 0 && net.ParseIP(pattern[:i]) != nil {
+		return nil, errors.Errorf("invalid CIDR block '%s'", pattern)
+	}
+	return expandPattern(pattern)
+}
+
+// expandPattern expands the ranges and alternations of a pattern, left to right.
+func expandPattern(pattern string) ([]string, error) {
+	hosts := []string{""}
+	for rest := pattern; rest != ""; {
+		var alts []string
+		i := strings.IndexAny(rest, "[]{}")
+		switch {
+		case i < 0:
+			alts, rest = []string{rest}, ""
+		case i > 0:
+			alts, rest = []string{rest[:i]}, rest[i:]
+		case rest[0] == '[' || rest[0] == '{':
+			closing := byte(']')
+			if rest[0] == '{' {
+				closing = '}'
+			}
+			end := strings.IndexByte(rest, closing)
+			if end < 0 {
+				return nil, errors.Errorf("unclosed '%c' in '%s'", rest[0], pattern)
+			}
+			var err error
+			if rest[0] == '[' {
+				alts, err = expandRange(rest[1:end])
+			} else {
+				alts, err = expandAlternation(rest[1:end])
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern '%s'", pattern)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, errors.Errorf("unexpected '%c' in '%s'", rest[0], pattern)
+		}
+
+		if len(hosts)*len(alts) > maxExpandedHosts {
+			return nil, errors.Errorf("pattern '%s' expands to more than %d hosts", pattern, maxExpandedHosts)
+		}
+		expanded := make([]string, 0, len(hosts)*len(alts))
+		for _, h := range hosts {
+			for _, a := range alts {
+				expanded = append(expanded, h+a)
+			}
+		}
+		hosts = expanded
+	}
+	return hosts, nil
+}
+
+// expandRange expands the numeric range START-END.  Numbers are padded with zeros to the width
+// of START when START has a leading zero.
+func expandRange(r string) ([]string, error) {
+	parts := strings.Split(r, "-")
+	if len(parts) != 2 || !isDigits(parts[0]) || !isDigits(parts[1]) {
+		return nil, errors.Errorf("range '[%s]' must be given as [START-END]", r)
+	}
+	start, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid range start '%s'", parts[0])
+	}
+	end, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid range end '%s'", parts[1])
+	}
+	if start > end {
+		return nil, errors.Errorf("range '[%s]' ends before it starts", r)
+	}
+	if end-start >= maxExpandedHosts {
+		return nil, errors.Errorf("range '[%s]' is more than %d hosts", r, maxExpandedHosts)
+	}
+
+	width := 0
+	if len(parts[0]) > 1 && parts[0][0] == '0' {
+		width = len(parts[0])
+	}
+	var values []string
+	for n := start; ; n++ {
+		v := strconv.FormatUint(n, 10)
+		if len(v) < width {
+			v = strings.Repeat("0", width-len(v)) + v
+		}
+		values = append(values, v)
+		if n == end {
+			return values, nil
+		}
+	}
+}
+
+// expandAlternation expands the comma separated alternatives A,B,C, each of which may itself
+// hold ranges.
+func expandAlternation(a string) ([]string, error) {
+	var values []string
+	for _, alt := range strings.Split(a, ",") {
+		expanded, err := expandPattern(alt)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, expanded...)
+	}
+	return values, nil
+}
+
+// expandCIDR returns the host addresses of a CIDR block.
+func expandCIDR(block string) ([]string, error) {
+	_, ipNet, err := net.ParseCIDR(block)
+	if err != nil {
+		return nil, errors.Errorf("invalid CIDR block '%s'", block)
+	}
+	ones, bits := ipNet.Mask.Size()
+	if 1<<uint(bits-ones) > maxExpandedHosts || bits-ones >= 32 {
+		return nil, errors.Errorf("CIDR block '%s' is more than %d hosts", block, maxExpandedHosts)
+	}
+
+	count := 1 << uint(bits-ones)
+	ip := make(net.IP, len(ipNet.IP))
+	copy(ip, ipNet.IP)
+	var hosts []string
+	for i := 0; i < count; i++ {
+		network, broadcast := i == 0, i == count-1
+		if !(bits == 32 && count > 2 && (network || broadcast)) {
+			hosts = append(hosts, ip.String())
+		}
+		incrementIP(ip)
+	}
+	return hosts, nil
+}
+
+// incrementIP adds one to ip in place.
+func incrementIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			return
+		}
+	}
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExpandingHostPatterns(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		pattern  string
+		expHosts []string
+	}{
+		{"host1", []string{"host1"}},
+		{"web-[1-3].dc1", []string{"web-1.dc1", "web-2.dc1", "web-3.dc1"}},
+		{"web-[08-11]", []string{"web-08", "web-09", "web-10", "web-11"}},
+		{"web-[001-003]", []string{"web-001", "web-002", "web-003"}},
+		{"web-[9-10]", []string{"web-9", "web-10"}},
+		{"api{a,b}-[0-1]", []string{"apia-0", "apia-1", "apib-0", "apib-1"}},
+		{"{web,api-[1-2]}.dc1", []string{"web.dc1", "api-1.dc1", "api-2.dc1"}},
+		{"10.2.0.0/30", []string{"10.2.0.1", "10.2.0.2"}},
+		{"10.2.0.9/29", []string{"10.2.0.9", "10.2.0.10", "10.2.0.11", "10.2.0.12", "10.2.0.13", "10.2.0.14"}},
+		{"10.2.0.4/31", []string{"10.2.0.4", "10.2.0.5"}},
+		{"10.2.0.7/32", []string{"10.2.0.7"}},
+		{"fd00::/127", []string{"fd00::", "fd00::1"}},
+		{"team/host1", []string{"team/host1"}},
+		{"team/web-[1-2]", []string{"team/web-1", "team/web-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			hosts, err := ExpandHostPattern(tt.pattern)
+			assert.Nil(err)
+			assert.Equal(tt.expHosts, hosts)
+		})
+	}
+
+	hosts, err := ExpandHostPattern("10.2.0.0/28")
+	assert.Nil(err)
+	assert.Len(hosts, 14)
+}
+
+func TestExpandingInvalidHostPatterns(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	for _, pattern := range []string{
+		"web-[1-3",
+		"web-{a,b",
+		"web-1]",
+		"web-}",
+		"web-[3-1]",
+		"web-[a-c]",
+		"web-[1]",
+		"web-[1-2-3]",
+		"web-[0-9999999]",
+		"web-[0-999][0-9999]",
+		"10.2.0.0/33",
+		"10.0.0.0/8",
+		"fd00::/96x",
+	} {
+		_, err := ExpandHostPattern(pattern)
+		assert.NotNil(err, pattern)
+	}
+}
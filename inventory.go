@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Inventory formats.
+const (
+	InventoryPlain = "plain"
+	InventoryYAML  = "yaml"
+	InventoryJSON  = "json"
+	InventoryCSV   = "csv"
+)
+
+// inventoryEntry is a single host of a structured inventory.
+type inventoryEntry struct {
+	Name   string            `json:"name" yaml:"name"`
+	URL    string            `json:"url" yaml:"url"`
+	Port   int               `json:"port" yaml:"port"`
+	Scheme string            `json:"scheme" yaml:"scheme"`
+	DC     string            `json:"dc" yaml:"dc"`
+	Env    string            `json:"env" yaml:"env"`
+	Team   string            `json:"team" yaml:"team"`
+	Labels map[string]string `json:"labels" yaml:"labels"`
+}
+
+// inventoryDocument is a structured inventory given as an object rather than a list of hosts.
+type inventoryDocument struct {
+	Hosts []inventoryEntry `json:"hosts" yaml:"hosts"`
+}
+
+// inventoryCSVColumns are the CSV columns mapped onto inventory entry fields.  Any other column
+// is taken as a label.
+var inventoryCSVColumns = map[string]func(e *inventoryEntry, v string) error{
+	"name":   func(e *inventoryEntry, v string) error { e.Name = v; return nil },
+	"url":    func(e *inventoryEntry, v string) error { e.URL = v; return nil },
+	"scheme": func(e *inventoryEntry, v string) error { e.Scheme = v; return nil },
+	"dc":     func(e *inventoryEntry, v string) error { e.DC = v; return nil },
+	"env":    func(e *inventoryEntry, v string) error { e.Env = v; return nil },
+	"team":   func(e *inventoryEntry, v string) error { e.Team = v; return nil },
+	"port": func(e *inventoryEntry, v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Errorf("invalid port '%s'", v)
+		}
+		e.Port = port
+		return nil
+	},
+}
+
+// stdinInventory is the inventory path which reads the inventory from stdin.
+const stdinInventory = "-"
+
+// Inventory loads hosts from one or more inventories, in any of the inventory formats, and from
+// DNS discovery sources.  A plain hosts file may include other inventories with an @include line,
+// naming a path relative to its own, and the exclude lines of a hosts file also exclude the hosts
+// of every inventory it includes.  Hosts are loaded again, and discovery sources resolved again,
+// on every call to Load, except from stdin, which is read once.
+type Inventory struct {
+	// Paths lists the inventories to load, where - is stdin.
+	Paths []string
+	// Stdin is read for the path -.
+	Stdin io.Reader
+	// Discover lists the DNS discovery sources to resolve after loading the inventories.
+	Discover []Discovery
+	// Resolver resolves the discovery sources.  net.DefaultResolver is used when Resolver is nil.
+	Resolver *net.Resolver
+
+	stdin     []byte
+	stdinRead bool
+}
+
+// NewInventory creates an Inventory loading the inventories at paths.
+func NewInventory(paths []string) *Inventory {
+	return &Inventory{Paths: paths, Stdin: os.Stdin}
+}
+
+// NewFlagInventory creates an Inventory loading the hosts files and discovery sources given by
+// flag, resolving discovery sources through its resolver.
+func NewFlagInventory(flag Flag) (*Inventory, error) {
+	discoveries, err := ParseDiscoveries(flag.Discover)
+	if err != nil {
+		return nil, err
+	}
+	inv := NewInventory(flag.HostsFiles)
+	inv.Discover = discoveries
+	inv.Resolver = NewResolver(flag.Resolver)
+	return inv, nil
+}
+
+// Load reads every host of every inventory and discovery source.  Hosts are the same when they
+// have the same status URL, and a host given more than once, whether by one source or several,
+// is kept only where it is first given, with a warning.
+func (inv *Inventory) Load() ([]Host, error) {
+	var hosts []Host
+	for _, path := range inv.Paths {
+		loaded, err := inv.load(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, loaded...)
+	}
+
+	if len(inv.Discover) == 0 {
+		return uniqueHosts(hosts), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	for _, d := range inv.Discover {
+		discovered, err := d.Hosts(ctx, inv.Resolver)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to discover hosts from '%s'", d)
+		}
+		log.Debugf("discovered %d hosts from %s", len(discovered), d)
+		hosts = append(hosts, discovered...)
+	}
+	return uniqueHosts(hosts), nil
+}
+
+// uniqueHosts returns the first of the hosts sharing a status URL, in order, warning of the others.
+func uniqueHosts(hosts []Host) []Host {
+	seen := make(map[string]Host, len(hosts))
+	unique := hosts[:0]
+	for _, h := range hosts {
+		if prev, ok := seen[h.key()]; ok {
+			log.Warnf("host '%s' from %s is already given by %s", h.Name, h.Origin(), prev.Origin())
+			continue
+		}
+		seen[h.key()] = h
+		unique = append(unique, h)
+	}
+	return unique
+}
+
+// load reads the hosts of the inventory at path, and of any inventory it includes.  Including
+// lists the absolute path of every inventory which includes path, outermost first, to detect
+// include cycles.
+func (inv *Inventory) load(path string, including []string) ([]Host, error) {
+	data, source, err := inv.read(path)
+	if err != nil {
+		return nil, err
+	}
+	key := source
+	if path != stdinInventory {
+		if key, err = filepath.Abs(path); err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve '%s'", path)
+		}
+	}
+	for i, p := range including {
+		if p == key {
+			return nil, errors.Errorf("include cycle %s -> %s", strings.Join(including[i:], " -> "), key)
+		}
+	}
+
+	format := DetectInventoryFormat(path, data)
+	if format != InventoryPlain {
+		hosts, err := ParseInventory(data, format)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read in hosts from '%s'", source)
+		}
+		for i := range hosts {
+			hosts[i].Source = source
+		}
+		return hosts, nil
+	}
+
+	lines, excluded, err := readHostsFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read in hosts from '%s'", source)
+	}
+	var hosts []Host
+	for _, l := range lines {
+		if l.Include == "" {
+			h, err := inventoryEntry{Name: l.Host}.host()
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read in hosts from '%s': line %d", source, l.Line)
+			}
+			h.Source, h.Line = source, l.Line
+			hosts = append(hosts, h)
+			continue
+		}
+
+		if l.Include == stdinInventory {
+			return nil, errors.Errorf("unable to read in hosts from '%s': line %d: stdin cannot be included", source, l.Line)
+		}
+		include := l.Include
+		if !filepath.IsAbs(include) && path != stdinInventory {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		included, err := inv.load(include, append(including[:len(including):len(including)], key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read in hosts from '%s': line %d", source, l.Line)
+		}
+		hosts = append(hosts, included...)
+	}
+
+	kept := hosts[:0]
+	for _, h := range hosts {
+		if !excluded[h.Name] {
+			kept = append(kept, h)
+		}
+	}
+	return kept, nil
+}
+
+// read returns the content of the inventory at path and the name it is known by.
+func (inv *Inventory) read(path string) ([]byte, string, error) {
+	if path != stdinInventory {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, path, errors.Wrapf(err, "unable to open file '%s'", path)
+		}
+		return data, path, nil
+	}
+
+	if !inv.stdinRead {
+		if inv.Stdin == nil {
+			return nil, "stdin", errors.New("no stdin to read hosts from")
+		}
+		data, err := ioutil.ReadAll(inv.Stdin)
+		if err != nil {
+			return nil, "stdin", errors.Wrap(err, "unable to read hosts from stdin")
+		}
+		inv.stdin, inv.stdinRead = data, true
+	}
+	return inv.stdin, "stdin", nil
+}
+
+// DetectInventoryFormat returns the format of an inventory, taken from the extension of its path
+// or, failing that, from its content, ignoring any leading comment lines.
+func DetectInventoryFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return InventoryYAML
+	case ".json":
+		return InventoryJSON
+	case ".csv":
+		return InventoryCSV
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return InventoryPlain
+	}
+	switch trimmed[0] {
+	case '[', '{':
+		// a plain hosts file may also start with a range or alternation
+		if json.Valid(trimmed) {
+			return InventoryJSON
+		}
+	}
+	var first string
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			first = line
+			break
+		}
+	}
+	switch {
+	case first == "---" || strings.HasPrefix(first, "- ") || strings.HasPrefix(first, "hosts:"):
+		return InventoryYAML
+	case strings.Contains(first, ","):
+		for _, col := range strings.Split(first, ",") {
+			if strings.EqualFold(strings.TrimSpace(col), "name") || strings.EqualFold(strings.TrimSpace(col), "url") {
+				return InventoryCSV
+			}
+		}
+	}
+	return InventoryPlain
+}
+
+// ParseInventory parses the hosts of an inventory in the given format.  Plain inventories list
+// a host on every line, while YAML and JSON inventories are a list of hosts, or an object with
+// a hosts list, and CSV inventories have a header row naming their columns.  A host given more
+// than once, with the same status URL, is kept only where it is first given, with a warning.
+func ParseInventory(data []byte, format string) ([]Host, error) {
+	var (
+		entries []inventoryEntry
+		err     error
+	)
+	switch format {
+	case InventoryPlain:
+		names, err := ReadAllHosts(ioutil.NopCloser(bytes.NewReader(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			entries = append(entries, inventoryEntry{Name: name})
+		}
+	case InventoryYAML:
+		entries, err = parseYAMLInventory(data)
+	case InventoryJSON:
+		entries, err = parseJSONInventory(data)
+	case InventoryCSV:
+		entries, err = parseCSVInventory(data)
+	default:
+		return nil, errors.Errorf("unknown inventory format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]Host, 0, len(entries))
+	seen := make(map[string]int)
+	for i, e := range entries {
+		h, err := e.host()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid host %d", i+1)
+		}
+		if prev, ok := seen[h.key()]; ok {
+			log.Warnf("host %d duplicates host %d, '%s'", i+1, prev, h.Name)
+			continue
+		}
+		seen[h.key()] = i + 1
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// parseYAMLInventory parses a list of hosts, or an object with a hosts list, rejecting unknown fields.
+func parseYAMLInventory(data []byte) ([]inventoryEntry, error) {
+	var top interface{}
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return nil, errors.Wrap(err, "invalid yaml inventory")
+	}
+	if _, ok := top.([]interface{}); ok || top == nil {
+		var entries []inventoryEntry
+		return entries, errors.Wrap(yaml.UnmarshalStrict(data, &entries), "invalid yaml inventory")
+	}
+	var doc inventoryDocument
+	return doc.Hosts, errors.Wrap(yaml.UnmarshalStrict(data, &doc), "invalid yaml inventory")
+}
+
+// parseJSONInventory parses a list of hosts, or an object with a hosts list, rejecting unknown fields.
+func parseJSONInventory(data []byte) ([]inventoryEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []inventoryEntry
+		return entries, errors.Wrap(dec.Decode(&entries), "invalid json inventory")
+	}
+	var doc inventoryDocument
+	return doc.Hosts, errors.Wrap(dec.Decode(&doc), "invalid json inventory")
+}
+
+// parseCSVInventory parses hosts from CSV with a header row.  Columns which are not inventory
+// fields become labels named by their header.
+func parseCSVInventory(data []byte) ([]inventoryEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid csv inventory header")
+	}
+	columns := make(map[string]bool, len(header))
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		if columns[header[i]] {
+			return nil, errors.Errorf("csv inventory column '%s' is given more than once, ignoring case", header[i])
+		}
+		columns[header[i]] = true
+	}
+
+	var entries []inventoryEntry
+	for n := 1; ; n++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid csv inventory")
+		}
+
+		var e inventoryEntry
+		for i, v := range record {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			set, ok := inventoryCSVColumns[header[i]]
+			if !ok {
+				if e.Labels == nil {
+					e.Labels = make(map[string]string)
+				}
+				e.Labels[header[i]] = v
+				continue
+			}
+			if err := set(&e, v); err != nil {
+				return nil, errors.Wrapf(err, "invalid csv inventory record %d", n)
+			}
+		}
+		entries = append(entries, e)
+	}
+}
+
+// host validates the entry and creates its Host.  A host given only by URL is named by the
+// host of the URL.
+func (e inventoryEntry) host() (Host, error) {
+	h := Host{
+		Name:        strings.TrimSpace(e.Name),
+		URL:         strings.TrimSpace(e.URL),
+		Port:        e.Port,
+		Scheme:      strings.ToLower(strings.TrimSpace(e.Scheme)),
+		Datacenter:  e.DC,
+		Environment: e.Env,
+		Team:        e.Team,
+	}
+
+	// label names are matched in lower case by selectors and group by dimensions, as CSV
+	// headers are
+	names := make([]string, 0, len(e.Labels))
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, ok := h.Labels[key]; ok {
+			return h, errors.Errorf("label '%s' is given more than once, ignoring case", key)
+		}
+		if h.Labels == nil {
+			h.Labels = make(map[string]string, len(e.Labels))
+		}
+		h.Labels[key] = e.Labels[name]
+	}
+
+	if h.URL != "" {
+		u, err := url.Parse(h.URL)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return h, errors.Errorf("url '%s' must be an absolute http or https url", h.URL)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return h, errors.Errorf("url '%s' must be an absolute http or https url", h.URL)
+		}
+		if h.Port != 0 || h.Scheme != "" {
+			return h, errors.Errorf("url '%s' cannot be combined with a port or scheme", h.URL)
+		}
+		if h.Name == "" {
+			h.Name = u.Host
+		}
+	}
+	if h.Name == "" {
+		return h, errors.New("a name or url is required")
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return h, errors.Errorf("port %d must be between 1 and 65535", h.Port)
+	}
+	if h.Scheme != "" && h.Scheme != "http" && h.Scheme != "https" {
+		return h, errors.Errorf("scheme '%s' must be http or https", h.Scheme)
+	}
+	return h, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectingInventoryFormats(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		path      string
+		data      string
+		expFormat string
+	}{
+		{"hosts.yaml", "", InventoryYAML},
+		{"hosts.YML", "", InventoryYAML},
+		{"hosts.json", "", InventoryJSON},
+		{"hosts.csv", "", InventoryCSV},
+		{"hosts.txt", "host1\nhost2\n", InventoryPlain},
+		{"hosts", "", InventoryPlain},
+		{"hosts", "  [{\"name\": \"host1\"}]", InventoryJSON},
+		{"hosts", "{\"hosts\": []}", InventoryJSON},
+		{"hosts", "---\n- name: host1\n", InventoryYAML},
+		{"hosts", "- name: host1\n", InventoryYAML},
+		{"hosts", "hosts:\n  - name: host1\n", InventoryYAML},
+		{"hosts", "name,dc\nhost1,eu\n", InventoryCSV},
+		{"hosts", "host1,host2\n", InventoryPlain},
+		{"hosts", "{web,api}-[1-3]\n", InventoryPlain},
+		{"hosts", "[web]\n", InventoryPlain},
+		{"hosts", "# name, url\nhost1\n", InventoryPlain},
+		{"hosts", "# inventory\nname,url\nhost1,\n", InventoryCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.expFormat, func(t *testing.T) {
+			assert.Equal(tt.expFormat, DetectInventoryFormat(tt.path, []byte(tt.data)))
+		})
+	}
+}
+
+func TestParsingInventories(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	expected := []Host{
+		{Name: "host1", Datacenter: "eu-west", Environment: "prod", Team: "web", Labels: map[string]string{"cluster": "a"}},
+		{Name: "host2", Port: 8443, Scheme: "https", Datacenter: "us-east", Environment: "staging"},
+		{Name: "host3.example.com", URL: "http://host3.example.com/health"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"yaml list", InventoryYAML, `
+- name: host1
+  dc: eu-west
+  env: prod
+  team: web
+  labels:
+    cluster: a
+- name: host2
+  port: 8443
+  scheme: HTTPS
+  dc: us-east
+  env: staging
+- url: http://host3.example.com/health
+`},
+		{"yaml document", InventoryYAML, `
+hosts:
+  - {name: host1, dc: eu-west, env: prod, team: web, labels: {cluster: a}}
+  - {name: host2, port: 8443, scheme: https, dc: us-east, env: staging}
+  - {url: "http://host3.example.com/health"}
+`},
+		{"yaml mixed case label", InventoryYAML, `
+- {name: host1, dc: eu-west, env: prod, team: web, labels: {Cluster: a}}
+- {name: host2, port: 8443, scheme: https, dc: us-east, env: staging}
+- {url: "http://host3.example.com/health"}
+`},
+		{"json list", InventoryJSON, `[
+  {"name": "host1", "dc": "eu-west", "env": "prod", "team": "web", "labels": {"cluster": "a"}},
+  {"name": "host2", "port": 8443, "scheme": "https", "dc": "us-east", "env": "staging"},
+  {"url": "http://host3.example.com/health"}
+]`},
+		{"json document", InventoryJSON, `{"hosts": [
+  {"name": "host1", "dc": "eu-west", "env": "prod", "team": "web", "labels": {"cluster": "a"}},
+  {"name": "host2", "port": 8443, "scheme": "https", "dc": "us-east", "env": "staging"},
+  {"url": "http://host3.example.com/health"}
+]}`},
+		{"csv", InventoryCSV, `name,url,port,scheme,dc,env,team,cluster
+# comments are ignored
+host1,,,,eu-west,prod,web,a
+host2,, 8443,https,us-east,staging,,
+,http://host3.example.com/health,,,,,,
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := ParseInventory([]byte(tt.data), tt.format)
+			assert.Nil(err)
+			assert.Equal(expected, hosts)
+		})
+	}
+}
+
+func TestParsingInventoriesKeepsFirstOfDuplicateHosts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	hosts, err := ParseInventory([]byte(`
+- {name: host1, env: prod}
+- {name: host1, port: 8080}
+- {name: host1, env: staging}
+- {name: host1, port: 8080, scheme: http}
+- {name: host1, port: 8081}
+- {url: "http://host1:8081/status"}
+`), InventoryYAML)
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "host1", Environment: "prod"},
+		{Name: "host1", Port: 8080},
+		{Name: "host1", Port: 8081},
+	}, hosts)
+}
+
+func TestParsingInvalidInventories(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"unknown yaml field", InventoryYAML, "- name: host1\n  datacenter: eu\n"},
+		{"unknown json field", InventoryJSON, `[{"name": "host1", "dc": "eu", "owner": "me"}]`},
+		{"missing name", InventoryJSON, `[{"dc": "eu"}]`},
+		{"labels differing in case", InventoryYAML, "- name: host1\n  labels: {cluster: a, Cluster: b}\n"},
+		{"csv columns differing in case", InventoryCSV, "name,cluster,Cluster\nhost1,a,b\n"},
+		{"invalid port", InventoryJSON, `[{"name": "host1", "port": 70000}]`},
+		{"invalid csv port", InventoryCSV, "name,port\nhost1,http\n"},
+		{"invalid scheme", InventoryYAML, "- name: host1\n  scheme: ftp\n"},
+		{"relative url", InventoryJSON, `[{"url": "/host1/status"}]`},
+		{"url with port", InventoryJSON, `[{"url": "http://host1/status", "port": 80}]`},
+		{"malformed json", InventoryJSON, `[{"name": "host1"`},
+		{"unknown format", "toml", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseInventory([]byte(tt.data), tt.format)
+			assert.NotNil(err)
+		})
+	}
+}
+
+// loadTestHosts loads the hosts of the inventories at paths as the hosts files given on the
+// command line are loaded.
+func loadTestHosts(paths ...string) ([]Host, error) {
+	inv, err := NewFlagInventory(Flag{HostsFiles: paths})
+	if err != nil {
+		return nil, err
+	}
+	return inv.Load()
+}
+
+func TestLoadingHostsFromInventoryFiles(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "hosts.txt")
+	assert.Nil(ioutil.WriteFile(plain, []byte("host1\n\n  host2\n"), 0644))
+	hosts, err := loadTestHosts(plain)
+	assert.Nil(err)
+	assert.Equal([]Host{{Name: "host1", Source: plain, Line: 1}, {Name: "host2", Source: plain, Line: 3}}, hosts)
+
+	structured := filepath.Join(dir, "inventory")
+	assert.Nil(ioutil.WriteFile(structured, []byte("- name: host1\n  env: prod\n"), 0644))
+	hosts, err = loadTestHosts(structured)
+	assert.Nil(err)
+	assert.Equal([]Host{{Name: "host1", Environment: "prod", Source: structured}}, hosts)
+
+	_, err = loadTestHosts(filepath.Join(dir, "missing.txt"))
+	assert.NotNil(err)
+}
+
+func TestLoadingHostsFromSeveralInventories(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"hosts.txt":           "web-[1-3]\n@include more/db.txt # databases\n!web-2\n",
+		"more/db.txt":         "db-1\nweb-3\n@include ../inventory.yaml\n",
+		"inventory.yaml":      "- name: cache-1\n  env: prod\n",
+		"cycle/a.txt":         "a\n@include b.txt\n",
+		"cycle/b.txt":         "b\n@include a.txt\n",
+		"invalid/hosts.txt":   "ok\n@include bad.txt\n",
+		"invalid/bad.txt":     "bad\nweb-[1-\n",
+		"invalid/missing.txt": "@include nowhere.txt\n",
+		"ports/a.yaml":        "- {name: host1, port: 8080}\n",
+		"ports/b.yaml":        "- {name: host1, port: 8081}\n- {name: host1, port: 8080, env: prod}\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(ioutil.WriteFile(path, []byte(data), 0644))
+	}
+	hostsFile := filepath.Join(dir, "hosts.txt")
+	dbFile := filepath.Join(dir, "more", "db.txt")
+	inventoryFile := filepath.Join(dir, "more", "..", "inventory.yaml")
+
+	inv, err := NewFlagInventory(Flag{HostsFiles: []string{hostsFile, "-", filepath.Join(dir, "inventory.yaml")}})
+	assert.Nil(err)
+	inv.Stdin = strings.NewReader("web-1\nweb-2\nweb-4\n")
+	expected := []Host{
+		{Name: "web-1", Source: hostsFile, Line: 1},
+		{Name: "web-3", Source: hostsFile, Line: 1},
+		{Name: "db-1", Source: dbFile, Line: 1},
+		{Name: "cache-1", Environment: "prod", Source: inventoryFile},
+		{Name: "web-2", Source: "stdin", Line: 2},
+		{Name: "web-4", Source: "stdin", Line: 3},
+	}
+	hosts, err := inv.Load()
+	assert.Nil(err)
+	assert.Equal(expected, hosts)
+
+	// stdin is read only once, and loaded again from what was read
+	hosts, err = inv.Load()
+	assert.Nil(err)
+	assert.Equal(expected, hosts)
+
+	_, err = loadTestHosts(filepath.Join(dir, "cycle", "a.txt"))
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "include cycle "+filepath.Join(dir, "cycle", "a.txt")+" -> "+filepath.Join(dir, "cycle", "b.txt")+" -> "+filepath.Join(dir, "cycle", "a.txt"))
+	}
+
+	_, err = loadTestHosts(filepath.Join(dir, "invalid", "hosts.txt"))
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "'"+filepath.Join(dir, "invalid", "hosts.txt")+"': line 2: unable to read in hosts from '"+filepath.Join(dir, "invalid", "bad.txt")+"': line 2")
+	}
+
+	_, err = loadTestHosts(filepath.Join(dir, "invalid", "missing.txt"))
+	assert.NotNil(err)
+
+	// hosts are the same only when they share a status URL
+	hosts, err = loadTestHosts(filepath.Join(dir, "ports", "a.yaml"), filepath.Join(dir, "ports", "b.yaml"))
+	assert.Nil(err)
+	assert.Equal([]Host{
+		{Name: "host1", Port: 8080, Source: filepath.Join(dir, "ports", "a.yaml")},
+		{Name: "host1", Port: 8081, Source: filepath.Join(dir, "ports", "b.yaml")},
+	}, hosts)
+}
@@ -1,75 +1,131 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"io"
 	"os"
-	"sync"
 	"time"
 )
 
 // buildVersion should be populated at build time by build ldflags
 var buildVersion string
 
-func init() {
-	Apps = make(map[Application]Metric)
-}
+// Exit codes.  A run which fails exits with 1, as from log.Fatal, and an invalid command line
+// exits with 2.
+const (
+	// exitCheckFailed is returned when the run completed but the report failed a check, such
+	// as a threshold violation or the regressions found by diff.
+	exitCheckFailed = 3
+	// exitInconclusive is returned when a check could not reach a verdict, such as a canary
+	// without enough requests.
+	exitInconclusive = 4
+)
 
 func main() {
-	start := time.Now()
-
 	var flag Flag
 	flag.Version = buildVersion
 	flag.Parse()
 
 	SetLogger(os.Stderr, flag.LogLevel, "text", false)
 
-	f, err := os.Open(flag.HostsFile)
-	if err != nil {
-		log.WithError(err).Fatalf("unable to open file '%s'", flag.HostsFile)
+	switch flag.Command {
+	case "serve":
+		runServe(flag)
+	case "history":
+		runHistory(flag)
+	case "diff":
+		runDiff(flag)
+	case "canary":
+		runCanary(flag)
+	default:
+		if flag.Watch > 0 {
+			runWatch(flag)
+			return
+		}
+		runReport(flag)
 	}
+}
 
-	hosts, err := ReadAllHosts(f)
+// runReport polls every host once and writes the report to every output.
+func runReport(flag Flag) {
+	sinks, err := newSinks(flag)
 	if err != nil {
-		log.WithError(err).Fatal("unable to read in hosts")
+		log.WithError(err).Fatal("unable to create report outputs")
 	}
 
-	var wg sync.WaitGroup
-	for _, h := range hosts {
-		wg.Add(1)
-		go func(h string) {
-			defer wg.Done()
-
-			statusURL, err := HostStatusURL(flag.RootURL, h)
-			if err != nil {
-				log.WithError(err).Errorf("could not create URL for host '%s'", h)
-			}
-			host := Host{URL: statusURL}
-
-			status, err := host.RequestHostStatus()
-			if err != nil {
-				log.WithError(err).Errorf("could not get status for host '%s'", h)
-			}
-			IncrementCounters(Apps, status)
-		}(h)
+	thresholds, err := flag.Thresholds()
+	if err != nil {
+		log.WithError(err).Fatal("invalid thresholds")
 	}
-	wg.Wait()
 
-	writeReport(os.Stdout)
-	fmt.Printf("\ncompleted in %s\n", time.Now().Sub(start).Truncate(time.Millisecond))
-}
+	report := pollOnce(flag)
 
-func writeReport(w io.Writer) {
-	for app, metrics := range Apps {
-		var successRate float32
-		if metrics.TotalSuccessCount == 0 || metrics.TotalRequestsCount == 0 {
-			successRate = 0
-		} else {
-			successRate = float32(metrics.TotalSuccessCount) / float32(metrics.TotalRequestsCount)
+	var sinkFailed bool
+	for _, sink := range sinks {
+		if err := sink.Write(report); err != nil {
+			log.WithError(err).Error("unable to write report")
+			sinkFailed = true
 		}
-		if _, err := fmt.Fprintf(w, "%s,%s,%.2f\n", app.Name, app.Version, successRate); err != nil {
-			log.WithError(err).Error("invalid printer format")
+	}
+	writeSummary(os.Stderr, report)
+	fmt.Fprintf(os.Stderr, "\n%d requests made to %d hosts (%d retries)", report.Attempts, report.HostsTotal, report.Retries)
+	fmt.Fprintf(os.Stderr, "\ncompleted in %s\n", report.Duration.Truncate(time.Millisecond))
+	if sinkFailed {
+		log.Fatal("one or more report outputs could not be written")
+	}
+
+	if violations := thresholds.Check(report); len(violations) > 0 {
+		fmt.Fprintf(os.Stderr, "\nthreshold violations:\n")
+		for _, v := range violations {
+			fmt.Fprintln(os.Stderr, v)
 		}
+		os.Exit(exitCheckFailed)
+	}
+}
+
+// pollOnce polls every host in the hosts files and discovery sources, recording the report in
+// the history.
+func pollOnce(flag Flag) *Report {
+	inventory, err := NewFlagInventory(flag)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create inventory")
+	}
+	hosts, err := inventory.Load()
+	if err != nil {
+		log.WithError(err).Fatal("unable to load hosts")
+	}
+
+	poller, err := NewPoller(flag)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create poller")
+	}
+
+	ctx := context.Background()
+	if flag.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flag.RunTimeout)
+		defer cancel()
+	}
+
+	report := poller.Poll(ctx, hosts)
+	saveHistory(flag, report)
+	return report
+}
+
+// newSinks creates the report outputs given on the command line.
+func newSinks(flag Flag) ([]Sink, error) {
+	delimiter, err := ParseCSVDelimiter(flag.CSVDelimiter)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := flag.OutputSpecs()
+	if err != nil {
+		return nil, err
 	}
+	return NewSinks(specs, RenderOptions{
+		CSV:      CSVOptions{Columns: flag.CSVColumns, Delimiter: delimiter, NoHeader: flag.NoHeader},
+		Table:    TableOptions{WarnBelow: flag.WarnBelow},
+		Template: flag.Template,
+	}, flag.Color)
 }
@@ -2,12 +2,8 @@ package main
 
 import "sync"
 
-var (
-	// Apps stores all tracked applications.
-	Apps map[Application]Metric
-	// AppLock guards additions to Apps to ensure thread safety
-	AppLock sync.Mutex
-)
+// AppLock guards additions to application metrics to ensure thread safety
+var AppLock sync.Mutex
 
 // Application represts a single version of a particular application.
 type Application struct {
@@ -20,6 +16,8 @@ type Metric struct {
 	TotalRequestsCount uint
 	TotalSuccessCount  uint
 	TotalErrorCount    uint
+	// HostCount is the number of hosts which reported the application version.
+	HostCount uint
 }
 
 func (m Metric) IncrementRequestCount(num uint) Metric {
@@ -37,6 +35,27 @@ func (m Metric) IncrementErrorCount(num uint) Metric {
 	return m
 }
 
+func (m Metric) IncrementHostCount(num uint) Metric {
+	m.HostCount += num
+	return m
+}
+
+// SuccessRate returns the fraction of requests which succeeded, or 0 when there were no requests.
+func (m Metric) SuccessRate() float64 {
+	if m.TotalRequestsCount == 0 {
+		return 0
+	}
+	return float64(m.TotalSuccessCount) / float64(m.TotalRequestsCount)
+}
+
+// ErrorRate returns the fraction of requests which failed, or 0 when there were no requests.
+func (m Metric) ErrorRate() float64 {
+	if m.TotalRequestsCount == 0 {
+		return 0
+	}
+	return float64(m.TotalErrorCount) / float64(m.TotalRequestsCount)
+}
+
 // IncrementCounters adds the status metrics to any applications defined in apps,
 // or creates new entries in apps where necessary.
 func IncrementCounters(apps map[Application]Metric, status HostStatus) {
@@ -49,8 +68,13 @@ func IncrementCounters(apps map[Application]Metric, status HostStatus) {
 	if ok {
 		m = apps[app]
 	}
+	apps[app] = m.AddStatus(status)
+}
+
+// AddStatus returns m with the counters of a single host's status added.
+func (m Metric) AddStatus(status HostStatus) Metric {
 	m = m.IncrementRequestCount(status.RequestsCount)
 	m = m.IncrementSuccessCount(status.SuccessCount)
 	m = m.IncrementErrorCount(status.ErrorCount)
-	apps[app] = m
+	return m.IncrementHostCount(1)
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// Poller polls every host once per call to Poll and aggregates their statuses into a Report.
+type Poller struct {
+	// Scheduler bounds how many hosts are polled at the same time.
+	Scheduler *Scheduler
+	// Retry decides whether failed status requests are retried.  A fresh retry budget of
+	// RetryBudget is given to every poll.
+	Retry RetryPolicy
+	// RetryBudget is the number of retries allowed during a single poll.  Zero means no limit.
+	RetryBudget int
+	// Client is shared by every host.
+	Client *http.Client
+	// RootURL is prepended to every host to create its status URL.
+	RootURL string
+	// MaxBodySize is the largest status response body accepted, in bytes.
+	MaxBodySize int64
+	// Version is the statusrep version recorded in each report.
+	Version string
+	// Sort orders the rows of each report.
+	Sort []SortKey
+	// GroupBy lists the dimensions the rows of each report are aggregated by.
+	GroupBy []string
+	// Select restricts the hosts polled to those matching every selector.
+	Select []Selector
+	// Samples holds the latest sample of every host, keyed by status URL, and is updated by every
+	// poll.  Each report counts the requests made since the previous sample of a host alongside
+	// its lifetime counts.  Samples of hosts which are no longer in the inventory are dropped.
+	Samples Samples
+	// StateFile is where Samples is saved after every poll, so that it carries over to later
+	// runs.  Samples are only kept in memory when empty.
+	StateFile string
+	// MaxSampleAge is how old a sample may be and still be kept.  Older samples are dropped, so
+	// that a host which reports again after a long absence does not count all of it as one
+	// interval.  Zero keeps samples for as long as their host is in the inventory.
+	MaxSampleAge time.Duration
+}
+
+// maxSampleIntervals is how many poll intervals samples are kept for in serve and watch mode.
+const maxSampleIntervals = 3
+
+// NewPoller creates a Poller configured by the command line flags.
+func NewPoller(flag Flag) (*Poller, error) {
+	sortKeys, err := ParseSortKeys(flag.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	groupBy, err := ParseGroupBy(flag.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	selectors, err := ParseSelectors(flag.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := Samples{}
+	if flag.StateFile != "" {
+		if samples, err = LoadSamples(flag.StateFile); err != nil {
+			return nil, err
+		}
+	}
+	var maxSampleAge time.Duration
+	switch {
+	case flag.Command == "serve":
+		maxSampleAge = maxSampleIntervals * flag.Interval
+	case flag.Watch > 0:
+		maxSampleAge = maxSampleIntervals * flag.Watch
+	}
+
+	return &Poller{
+		Scheduler: &Scheduler{Concurrency: flag.Concurrency, MaxInflightPerRoot: flag.MaxInflightPerRoot},
+		Retry: RetryPolicy{
+			MaxAttempts: flag.MaxAttempts,
+			BaseDelay:   flag.RetryDelay,
+			MaxDelay:    flag.RetryMaxDelay,
+		},
+		RetryBudget: flag.RetryBudget,
+		Client: NewHTTPClient(ClientConfig{
+			ConnectTimeout:      flag.ConnectTimeout,
+			TLSHandshakeTimeout: flag.TLSTimeout,
+			Timeout:             flag.Timeout,
+			MaxIdleConnsPerHost: flag.Concurrency,
+		}),
+		RootURL:      flag.RootURL,
+		MaxBodySize:  flag.MaxBodySize,
+		Version:      flag.Version,
+		Sort:         sortKeys,
+		GroupBy:      groupBy,
+		Select:       selectors,
+		Samples:      samples,
+		StateFile:    flag.StateFile,
+		MaxSampleAge: maxSampleAge,
+	}, nil
+}
+
+// Poll requests the status of every host and returns the aggregated Report.  Every poll
+// aggregates into a new Report, so that nothing is carried over from earlier polls.
+func (p *Poller) Poll(ctx context.Context, hosts []Host) *Report {
+	start := time.Now()
+
+	report := NewReport(make(map[Application]Metric))
+	report.Start = start
+	report.Version = p.Version
+	report.RootURL = p.RootURL
+	report.Sort = p.Sort
+	report.GroupBy = p.GroupBy
+
+	// hosts which are not polled, whether left out by the selectors or failing, keep their
+	// previous samples while they are in the inventory and the samples are recent enough
+	samples := make(Samples, len(hosts))
+	for _, h := range hosts {
+		statusURL, err := h.StatusURL(p.RootURL)
+		if err != nil {
+			continue
+		}
+		sample, ok := p.Samples[statusURL]
+		if ok && (p.MaxSampleAge <= 0 || start.Sub(sample.At) <= p.MaxSampleAge) {
+			samples[statusURL] = sample
+		}
+	}
+
+	hosts = SelectHosts(hosts, p.Select)
+
+	var targets []Host
+	for _, h := range hosts {
+		statusURL, err := h.StatusURL(p.RootURL)
+		if err != nil {
+			hostLog(h).WithError(err).Errorf("could not create URL for host '%s'", h.Name)
+			report.AddFailure(h, withKind(ErrInvalidURL, err), 0)
+			continue
+		}
+		h.URL = statusURL
+		h.Client = p.Client
+		h.MaxBodySize = p.MaxBodySize
+		targets = append(targets, h)
+	}
+
+	retry := p.Retry
+	if p.RetryBudget > 0 {
+		retry.Budget = NewRetryBudget(p.RetryBudget)
+	}
+
+	results := p.Scheduler.Run(targets, func(host Host) Result {
+		var status HostStatus
+		attempts, err := retry.Do(ctx, fmt.Sprintf("host '%s'", host.Name), func() error {
+			var err error
+			status, err = host.RequestHostStatus(ctx)
+			return err
+		})
+		return Result{Host: host, Status: status, Err: err, Attempts: attempts}
+	})
+
+	for r := range results {
+		if r.Err != nil {
+			hostLog(r.Host).WithError(r.Err).Errorf("could not get status for host '%s'", r.Host.Name)
+		}
+		report.Add(r)
+
+		prev, sampled := samples[r.Host.URL]
+		if r.Err != nil || r.Status.Application == "" {
+			continue
+		}
+		sample := NewSample(r.Status, time.Now())
+		samples[r.Host.URL] = sample
+		if !sampled {
+			continue
+		}
+		status, reset := sample.Since(prev)
+		if reset {
+			log.Infof("counters of host '%s' were reset since %s", r.Host.Name, prev.At.Format(time.RFC3339))
+		}
+		report.AddWindow(r.Host, status, prev.At, reset)
+	}
+	p.Samples = samples
+
+	if p.StateFile != "" {
+		if err := SaveSamples(p.StateFile, samples); err != nil {
+			log.WithError(err).Error("unable to save host samples")
+		}
+	}
+
+	report.Duration = time.Now().Sub(start)
+	return report
+}
+
+// hostLog returns a log entry recording where h was read from, when it is known.
+func hostLog(h Host) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger())
+	if h.Source != "" {
+		entry = entry.WithField("source", h.Origin())
+	}
+	return entry
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollerAggregatesEveryHost(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/down") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := fmt.Fprintf(w, `{"application": "app1", "Version": "1.0.0", "requests_count": 10, "success_count": 9, "error_count": 1}`)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	poller := &Poller{
+		Scheduler: &Scheduler{Concurrency: 2},
+		Retry:     RetryPolicy{MaxAttempts: 1},
+		RootURL:   ts.URL,
+	}
+
+	for i := 0; i < 2; i++ {
+		r := poller.Poll(context.Background(), []Host{{Name: "host1"}, {Name: "host2"}, {Name: "down1"}})
+		assert.Equal(3, r.HostsTotal)
+		assert.Equal(2, r.HostsReported)
+		assert.Equal(Metric{TotalRequestsCount: 20, TotalSuccessCount: 18, TotalErrorCount: 2, HostCount: 2}, r.Apps[Application{Name: "app1", Version: "1.0.0"}])
+		assert.Len(r.FailedHosts, 1)
+		assert.Equal("down1", r.FailedHosts[0].Host)
+	}
+}
+
+func TestPollerCountsRequestsSincePreviousPoll(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var requests uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint32(&requests, 1)
+		_, err := fmt.Fprintf(w, `{"application": "app1", "Version": "1.0.0", "requests_count": %d, "success_count": %d, "error_count": %d}`, n*100, n*90, n*10)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	poller := &Poller{
+		Scheduler: &Scheduler{Concurrency: 1},
+		Retry:     RetryPolicy{MaxAttempts: 1},
+		RootURL:   ts.URL,
+	}
+	app := Application{Name: "app1", Version: "1.0.0"}
+
+	r := poller.Poll(context.Background(), []Host{{Name: "host1"}})
+	assert.Empty(r.Windows)
+	assert.True(r.WindowStart.IsZero())
+
+	r = poller.Poll(context.Background(), []Host{{Name: "host1"}})
+	assert.Equal(Metric{TotalRequestsCount: 200, TotalSuccessCount: 180, TotalErrorCount: 20, HostCount: 1}, r.Apps[app])
+	assert.Equal(Metric{TotalRequestsCount: 100, TotalSuccessCount: 90, TotalErrorCount: 10, HostCount: 1}, r.Windows[app])
+	assert.False(r.WindowStart.IsZero())
+	assert.Equal(0, r.CounterResets)
+}
+
+func TestPollerKeepsSamplesOfHostsNotPolled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"application": "app1", "Version": "1.0.0", "requests_count": 10, "success_count": 9, "error_count": 1}`)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	poller := &Poller{
+		Scheduler: &Scheduler{Concurrency: 1},
+		Retry:     RetryPolicy{MaxAttempts: 1},
+		RootURL:   ts.URL,
+	}
+	hosts := []Host{{Name: "host1", Team: "a"}, {Name: "host2", Team: "b"}}
+
+	poller.Poll(context.Background(), hosts)
+	assert.Len(poller.Samples, 2)
+	host2 := poller.Samples[ts.URL+"/host2/status"]
+
+	poller.Select = []Selector{{Key: "team", Value: "a"}}
+	poller.Poll(context.Background(), hosts)
+	assert.Len(poller.Samples, 2)
+	assert.Equal(host2, poller.Samples[ts.URL+"/host2/status"])
+
+	// hosts which leave the inventory lose their samples
+	poller.Poll(context.Background(), hosts[:1])
+	assert.Len(poller.Samples, 1)
+	assert.Contains(poller.Samples, ts.URL+"/host1/status")
+}
+
+func TestPollerDropsSamplesOlderThanMaxAge(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"application": "app1", "Version": "1.0.0", "requests_count": 10, "success_count": 9, "error_count": 1}`)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	old := time.Now().Add(-time.Hour)
+	poller := &Poller{
+		Scheduler:    &Scheduler{Concurrency: 1},
+		Retry:        RetryPolicy{MaxAttempts: 1},
+		RootURL:      ts.URL,
+		MaxSampleAge: time.Minute,
+		Samples: Samples{
+			ts.URL + "/host1/status": {Application: "app1", Version: "1.0.0", RequestsCount: 5, At: old},
+			ts.URL + "/host2/status": {Application: "app1", Version: "1.0.0", RequestsCount: 5, At: old},
+		},
+		Select: []Selector{{Key: "host", Value: "host1"}},
+	}
+
+	r := poller.Poll(context.Background(), []Host{{Name: "host1"}, {Name: "host2"}})
+	assert.Empty(r.Windows)
+	assert.True(r.WindowStart.IsZero())
+	assert.Len(poller.Samples, 1)
+	assert.True(poller.Samples[ts.URL+"/host1/status"].At.After(old))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+var defaultOutputFormat = "csv"
+
+// outputFormats lists every supported output format.
+var outputFormats = []string{"csv", "json", "table", "markdown", "html", "prometheus", "template"}
+
+// Renderer writes a Report in a particular output format.
+type Renderer interface {
+	Render(w io.Writer, r *Report) error
+}
+
+// RendererFunc adapts a function to a Renderer.
+type RendererFunc func(w io.Writer, r *Report) error
+
+// Render calls fn(w, r).
+func (fn RendererFunc) Render(w io.Writer, r *Report) error {
+	return fn(w, r)
+}
+
+// RenderOptions holds the settings of every output format.
+type RenderOptions struct {
+	CSV   CSVOptions
+	Table TableOptions
+	// Template is the path of the text/template used by the template format.
+	Template string
+}
+
+// NewRenderer returns the Renderer for the named output format.
+func NewRenderer(format string, opts RenderOptions) (Renderer, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		if _, err := selectCSVColumns(opts.CSV.Columns); err != nil {
+			return nil, err
+		}
+		return RendererFunc(func(w io.Writer, r *Report) error {
+			return renderCSV(w, r, opts.CSV)
+		}), nil
+	case "json":
+		return RendererFunc(renderJSON), nil
+	case "table":
+		return RendererFunc(func(w io.Writer, r *Report) error {
+			return renderTable(w, r, opts.Table)
+		}), nil
+	case "markdown":
+		return RendererFunc(renderMarkdown), nil
+	case "html":
+		return RendererFunc(func(w io.Writer, r *Report) error {
+			return renderHTML(w, r, opts.Table.WarnBelow)
+		}), nil
+	case "prometheus":
+		return RendererFunc(renderPrometheus), nil
+	case "template":
+		if opts.Template == "" {
+			return nil, errors.New("the template format requires a template file")
+		}
+		tmpl, err := ParseReportTemplate(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		return RendererFunc(func(w io.Writer, r *Report) error {
+			return renderTemplate(w, r, tmpl)
+		}), nil
+	}
+	return nil, errors.Errorf("unknown output format '%s', expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// validOutputFormat reports whether format is a supported output format.
+func validOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if strings.EqualFold(f, format) {
+			return true
+		}
+	}
+	return false
+}
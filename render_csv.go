@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvColumn is a single column of the CSV report.
+type csvColumn struct {
+	name  string
+	value func(row AppRow) string
+	// optional columns are only written when selected by name.
+	optional bool
+}
+
+// csvColumns lists every available CSV column, in their default order.
+var csvColumns = []csvColumn{
+	{"name", func(row AppRow) string { return row.App.Name }, false},
+	{"version", func(row AppRow) string { return row.App.Version }, false},
+	{"requests", func(row AppRow) string { return strconv.FormatUint(uint64(row.Metric.TotalRequestsCount), 10) }, false},
+	{"successes", func(row AppRow) string { return strconv.FormatUint(uint64(row.Metric.TotalSuccessCount), 10) }, false},
+	{"errors", func(row AppRow) string { return strconv.FormatUint(uint64(row.Metric.TotalErrorCount), 10) }, false},
+	{"success_rate", func(row AppRow) string { return strconv.FormatFloat(row.Metric.SuccessRate(), 'f', 4, 64) }, false},
+	{"error_rate", func(row AppRow) string { return strconv.FormatFloat(row.Metric.ErrorRate(), 'f', 4, 64) }, false},
+	{"hosts", func(row AppRow) string { return strconv.FormatUint(uint64(row.Metric.HostCount), 10) }, false},
+	{"window_requests", func(row AppRow) string {
+		if row.Window == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(row.Window.TotalRequestsCount), 10)
+	}, true},
+	{"window_success_rate", func(row AppRow) string {
+		if row.Window == nil {
+			return ""
+		}
+		return strconv.FormatFloat(row.Window.SuccessRate(), 'f', 4, 64)
+	}, true},
+	{"window_error_rate", func(row AppRow) string {
+		if row.Window == nil {
+			return ""
+		}
+		return strconv.FormatFloat(row.Window.ErrorRate(), 'f', 4, 64)
+	}, true},
+}
+
+// CSVOptions controls how the CSV report is written.
+type CSVOptions struct {
+	// Columns names the columns to write, in order.  All columns are written when empty.
+	Columns []string
+	// Delimiter separates fields.  A comma is used when zero.
+	Delimiter rune
+	// NoHeader suppresses the header row.
+	NoHeader bool
+}
+
+// csvColumnNames returns the names of every available CSV column.
+func csvColumnNames() []string {
+	names := make([]string, len(csvColumns))
+	for i, c := range csvColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// selectCSVColumns returns the columns matching names, or every column which is not optional
+// when names is empty.
+func selectCSVColumns(names []string) ([]csvColumn, error) {
+	if len(names) == 0 {
+		var cols []csvColumn
+		for _, c := range csvColumns {
+			if !c.optional {
+				cols = append(cols, c)
+			}
+		}
+		return cols, nil
+	}
+
+	var cols []csvColumn
+	for _, n := range names {
+		var found bool
+		for _, c := range csvColumns {
+			if strings.EqualFold(c.name, strings.TrimSpace(n)) {
+				cols = append(cols, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown csv column '%s', expected one of %s", n, strings.Join(csvColumnNames(), ", "))
+		}
+	}
+	return cols, nil
+}
+
+// withCSVDimensionColumns adds a column for each dimension rows are grouped by, after the
+// version column, or first when the version column is not written.
+func withCSVDimensionColumns(cols []csvColumn, dims []string) []csvColumn {
+	if len(dims) == 0 {
+		return cols
+	}
+
+	dimCols := make([]csvColumn, len(dims))
+	for i, d := range dims {
+		i := i
+		dimCols[i] = csvColumn{name: d, value: func(row AppRow) string { return row.Group[i].Value }}
+	}
+	at := 0
+	for i, c := range cols {
+		if c.name == "version" {
+			at = i + 1
+		}
+	}
+	withDims := append([]csvColumn{}, cols[:at]...)
+	withDims = append(withDims, dimCols...)
+	return append(withDims, cols[at:]...)
+}
+
+// ParseCSVDelimiter parses a delimiter given on the command line.  The names "tab", "comma",
+// "semicolon" and "pipe" are accepted along with any single character.
+func ParseCSVDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "", "comma":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, errors.Errorf("csv delimiter '%s' must be a single character", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, errors.Errorf("invalid csv delimiter '%s'", s)
+	}
+	return r, nil
+}
+
+// renderCSV writes a row for every application version in r as RFC 4180 CSV.
+func renderCSV(w io.Writer, r *Report, opts CSVOptions) error {
+	cols, err := selectCSVColumns(opts.Columns)
+	if err != nil {
+		return err
+	}
+	cols = withCSVDimensionColumns(cols, r.Dimensions())
+
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+
+	if !opts.NoHeader {
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = c.name
+		}
+		if err := cw.Write(header); err != nil {
+			return errors.Wrap(err, "unable to write csv header")
+		}
+	}
+
+	for _, row := range r.Rows() {
+		record := make([]string, len(cols))
+		for i, c := range cols {
+			record[i] = c.value(row)
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.Wrap(err, "unable to write csv record")
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "unable to write csv report")
+}
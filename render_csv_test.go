@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func csvTestReport() *Report {
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "app, the first", Version: "v1", RequestsCount: 4, SuccessCount: 3, ErrorCount: 1}})
+	return r
+}
+
+func TestRenderingCSVReport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name   string
+		opts   CSVOptions
+		expOut string
+	}{
+		{
+			"all_columns",
+			CSVOptions{},
+			"name,version,requests,successes,errors,success_rate,error_rate,hosts\n\"app, the first\",v1,4,3,1,0.7500,0.2500,1\n",
+		},
+		{
+			"selected_columns",
+			CSVOptions{Columns: []string{"version", "success_rate", "hosts"}},
+			"version,success_rate,hosts\nv1,0.7500,1\n",
+		},
+		{
+			"no_header",
+			CSVOptions{Columns: []string{"name", "errors"}, NoHeader: true},
+			"\"app, the first\",1\n",
+		},
+		{
+			"tab_delimiter",
+			CSVOptions{Columns: []string{"name", "requests"}, Delimiter: '\t'},
+			"name\trequests\napp, the first\t4\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Nil(renderCSV(&buf, csvTestReport(), tt.opts))
+			assert.Equal(tt.expOut, buf.String())
+		})
+	}
+}
+
+func TestRenderingCSVWithUnknownColumnFails(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := renderCSV(&buf, csvTestReport(), CSVOptions{Columns: []string{"name", "latency"}})
+	assert.NotNil(err)
+}
+
+func TestParsingCSVDelimiter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    string
+		expDelim rune
+		expErr   bool
+	}{
+		{"", ',', false},
+		{"tab", '\t', false},
+		{"semicolon", ';', false},
+		{"|", '|', false},
+		{"::", 0, true},
+		{`"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			delim, err := ParseCSVDelimiter(tt.value)
+			assert.Equal(tt.expErr, err != nil)
+			assert.Equal(tt.expDelim, delim)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlReportTemplate renders a self-contained page, with no external stylesheets or scripts,
+// so that it can be attached to tickets or uploaded to a wiki as it is.
+var htmlReportTemplate = template.Must(template.New("report.html").Funcs(template.FuncMap{
+	"percent":  formatPercent,
+	"count":    formatCount,
+	"duration": func(d time.Duration) string { return d.Truncate(time.Millisecond).String() },
+	"rfc3339":  func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>statusrep report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #e1e4e8; text-align: left; }
+th.sortable { cursor: pointer; user-select: none; }
+th.sortable:after { content: " \2195"; color: #959da5; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #f1f1f1; width: 160px; height: 12px; display: inline-block; vertical-align: middle; }
+.bar span { background: #2cbe4e; height: 100%; display: block; }
+.warn .bar span { background: #cb2431; }
+.meta { color: #586069; }
+</style>
+</head>
+<body>
+<h1>statusrep report</h1>
+<p class="meta">{{.Hosts.Reported}}/{{.Hosts.Total}} hosts reported{{if not .Run.Start.IsZero}} at {{rfc3339 .Run.Start}} in {{duration .Run.Duration}}{{end}}{{if .Run.Version}} by statusrep {{.Run.Version}}{{end}}.</p>
+
+<table id="applications">
+<thead>
+<tr>
+<th class="sortable" data-type="text">Application</th>
+<th class="sortable" data-type="text">Version</th>
+{{- range .Dimensions}}
+<th class="sortable" data-type="text">{{.}}</th>
+{{- end}}
+<th class="sortable" data-type="num">Requests</th>
+<th class="sortable" data-type="num">Successes</th>
+<th class="sortable" data-type="num">Errors</th>
+<th class="sortable" data-type="num">Success Rate</th>
+<th class="sortable" data-type="num">Error Rate</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Applications}}
+<tr{{if lt .Metric.SuccessRate $.WarnBelow}} class="warn"{{end}}>
+<td>{{.App.Name}}</td>
+<td>{{.App.Version}}</td>
+{{- range .Group}}
+<td>{{.Value}}</td>
+{{- end}}
+<td class="num" data-value="{{.Metric.TotalRequestsCount}}">{{count .Metric.TotalRequestsCount}}</td>
+<td class="num" data-value="{{.Metric.TotalSuccessCount}}">{{count .Metric.TotalSuccessCount}}</td>
+<td class="num" data-value="{{.Metric.TotalErrorCount}}">{{count .Metric.TotalErrorCount}}</td>
+<td class="num" data-value="{{.Metric.SuccessRate}}"><span class="bar"><span style="width: {{percent .Metric.SuccessRate}}"></span></span> {{percent .Metric.SuccessRate}}</td>
+<td class="num" data-value="{{.Metric.ErrorRate}}">{{percent .Metric.ErrorRate}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{if .FailedHosts}}
+<h2>Appendix: failed hosts</h2>
+<table id="failed-hosts">
+<thead>
+<tr><th>Host</th><th>URL</th><th>Category</th><th>Message</th></tr>
+</thead>
+<tbody>
+{{- range .FailedHosts}}
+<tr><td>{{.Host}}</td><td>{{.URL}}</td><td>{{.Category}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("th.sortable").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table");
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var numeric = th.dataset.type === "num";
+    var asc = th.dataset.order !== "asc";
+    th.parentNode.querySelectorAll("th").forEach(function (h) { delete h.dataset.order; });
+    th.dataset.order = asc ? "asc" : "desc";
+    var rows = Array.prototype.slice.call(table.tBodies[0].rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[index], y = b.cells[index];
+      var c = numeric
+        ? parseFloat(x.dataset.value) - parseFloat(y.dataset.value)
+        : x.textContent.localeCompare(y.textContent, undefined, {numeric: true});
+      return asc ? c : -c;
+    });
+    rows.forEach(function (row) { table.tBodies[0].appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// htmlData is the data given to htmlReportTemplate.
+type htmlData struct {
+	TemplateData
+	// WarnBelow is the success rate under which rows are highlighted.
+	WarnBelow float64
+}
+
+// renderHTML writes r as a self-contained HTML page with sortable columns, success rate bars
+// and an appendix of failed hosts.  Rows with a success rate below warnBelow are highlighted.
+func renderHTML(w io.Writer, r *Report, warnBelow float64) error {
+	data := htmlData{TemplateData: NewTemplateData(r), WarnBelow: warnBelow}
+	if err := htmlReportTemplate.Execute(w, data); err != nil {
+		return errors.Wrap(err, "unable to write html report")
+	}
+	return nil
+}
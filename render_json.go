@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"time"
+)
+
+// reportSchemaVersion is the version of the ReportDocument layout.  It must be incremented
+// whenever a field is removed or its meaning changes, so that consumers can detect the change.
+//
+// Version 2 allows applications to be grouped by other dimensions, when each application row
+// holds the groups it is for and may leave name or version empty if they are not grouped by.
+const reportSchemaVersion = 2
+
+// ReportDocument is the JSON representation of a Report.
+type ReportDocument struct {
+	SchemaVersion      int                   `json:"schema_version"`
+	Run                RunDocument           `json:"run"`
+	Hosts              HostsDocument         `json:"hosts"`
+	Applications       []ApplicationDocument `json:"applications"`
+	FailedHosts        []FailedHostDocument  `json:"failed_hosts"`
+	FailuresByCategory map[string]int        `json:"failures_by_category"`
+}
+
+// RunDocument describes the run which produced a report.
+type RunDocument struct {
+	StatusrepVersion string    `json:"statusrep_version"`
+	RootURL          string    `json:"root_url"`
+	StartedAt        time.Time `json:"started_at"`
+	DurationSeconds  float64   `json:"duration_seconds"`
+	// WindowStartedAt is the time of the earliest previous sample counted in the application
+	// windows, and is omitted when no host was sampled before.
+	WindowStartedAt *time.Time `json:"window_started_at,omitempty"`
+	CounterResets   int        `json:"counter_resets"`
+	// GroupBy lists the dimensions applications are grouped by, and is omitted when they are
+	// grouped by application and version.
+	GroupBy []string `json:"group_by,omitempty"`
+}
+
+// HostsDocument summarizes how many hosts reported a status.
+type HostsDocument struct {
+	Total    int     `json:"total"`
+	Reported int     `json:"reported"`
+	Failed   int     `json:"failed"`
+	Coverage float64 `json:"coverage"`
+	Attempts int     `json:"attempts"`
+}
+
+// ApplicationDocument holds the metric totals of a single application version.
+type ApplicationDocument struct {
+	Name        string  `json:"name"`
+	Version     string  `json:"version"`
+	Requests    uint    `json:"requests"`
+	Successes   uint    `json:"successes"`
+	Errors      uint    `json:"errors"`
+	SuccessRate float64 `json:"success_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	Hosts       uint    `json:"hosts"`
+	// Groups holds the value of every dimension other than application and version the report
+	// is grouped by, and is omitted when there are none.
+	Groups map[string]string `json:"groups,omitempty"`
+	// Window holds the counts since the previous poll, and is omitted when no host running the
+	// application version was sampled before.
+	Window *WindowDocument `json:"window,omitempty"`
+}
+
+// WindowDocument holds the counts of a single application version since the previous poll.
+type WindowDocument struct {
+	Requests    uint    `json:"requests"`
+	Successes   uint    `json:"successes"`
+	Errors      uint    `json:"errors"`
+	SuccessRate float64 `json:"success_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	Hosts       uint    `json:"hosts"`
+}
+
+// FailedHostDocument describes a host which did not report a status.
+type FailedHostDocument struct {
+	Host     string `json:"host"`
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	Attempts int    `json:"attempts"`
+	Source   string `json:"source,omitempty"`
+}
+
+// NewReportDocument creates the JSON representation of r.
+func NewReportDocument(r *Report) ReportDocument {
+	doc := ReportDocument{
+		SchemaVersion: reportSchemaVersion,
+		Run: RunDocument{
+			StatusrepVersion: r.Version,
+			RootURL:          r.RootURL,
+			StartedAt:        r.Start,
+			DurationSeconds:  r.Duration.Seconds(),
+			CounterResets:    r.CounterResets,
+			GroupBy:          r.GroupBy,
+		},
+		Hosts: HostsDocument{
+			Total:    r.HostsTotal,
+			Reported: r.HostsReported,
+			Failed:   len(r.FailedHosts),
+			Coverage: float64(r.Coverage()),
+			Attempts: r.Attempts,
+		},
+		Applications:       []ApplicationDocument{},
+		FailedHosts:        []FailedHostDocument{},
+		FailuresByCategory: r.FailureCategories(),
+	}
+	if !r.WindowStart.IsZero() {
+		start := r.WindowStart
+		doc.Run.WindowStartedAt = &start
+	}
+	for _, row := range r.Rows() {
+		app := ApplicationDocument{
+			Name:        row.App.Name,
+			Version:     row.App.Version,
+			Requests:    row.Metric.TotalRequestsCount,
+			Successes:   row.Metric.TotalSuccessCount,
+			Errors:      row.Metric.TotalErrorCount,
+			SuccessRate: row.Metric.SuccessRate(),
+			ErrorRate:   row.Metric.ErrorRate(),
+			Hosts:       row.Metric.HostCount,
+		}
+		if len(row.Group) > 0 {
+			app.Groups = make(map[string]string, len(row.Group))
+			for _, d := range row.Group {
+				app.Groups[d.Name] = d.Value
+			}
+		}
+		if row.Window != nil {
+			app.Window = &WindowDocument{
+				Requests:    row.Window.TotalRequestsCount,
+				Successes:   row.Window.TotalSuccessCount,
+				Errors:      row.Window.TotalErrorCount,
+				SuccessRate: row.Window.SuccessRate(),
+				ErrorRate:   row.Window.ErrorRate(),
+				Hosts:       row.Window.HostCount,
+			}
+		}
+		doc.Applications = append(doc.Applications, app)
+	}
+	for _, f := range r.SortedFailedHosts() {
+		doc.FailedHosts = append(doc.FailedHosts, FailedHostDocument{
+			Host:     f.Host,
+			URL:      f.URL,
+			Category: f.Category,
+			Message:  f.Message,
+			Attempts: f.Attempts,
+			Source:   f.Source,
+		})
+	}
+	return doc
+}
+
+// renderJSON writes r as an indented ReportDocument.
+func renderJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(NewReportDocument(r)); err != nil {
+		return errors.Wrap(err, "unable to encode json report")
+	}
+	return nil
+}
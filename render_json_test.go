@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRenderingJSONReport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := NewReport(make(map[Application]Metric))
+	r.Start = start
+	r.Duration = 1500 * time.Millisecond
+	r.Version = "0.1.0-1559390400"
+	r.RootURL = "http://root.com"
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "v1", RequestsCount: 10, SuccessCount: 8, ErrorCount: 2}, Attempts: 1})
+	r.Add(Result{Host: Host{Name: "host2", URL: "http://root.com/host2/status", Source: "hosts.txt", Line: 2}, Err: withKind(ErrTimeout, ErrTimeout), Attempts: 3})
+
+	var buf bytes.Buffer
+	assert.Nil(renderJSON(&buf, r))
+
+	var doc ReportDocument
+	assert.Nil(json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(reportSchemaVersion, doc.SchemaVersion)
+	assert.Equal(RunDocument{StatusrepVersion: "0.1.0-1559390400", RootURL: "http://root.com", StartedAt: start, DurationSeconds: 1.5}, doc.Run)
+	assert.Equal(HostsDocument{Total: 2, Reported: 1, Failed: 1, Coverage: 0.5, Attempts: 4}, doc.Hosts)
+	assert.Equal([]ApplicationDocument{
+		{Name: "app1", Version: "v1", Requests: 10, Successes: 8, Errors: 2, SuccessRate: 0.8, ErrorRate: 0.2, Hosts: 1},
+	}, doc.Applications)
+	assert.Len(doc.FailedHosts, 1)
+	assert.Equal("host2", doc.FailedHosts[0].Host)
+	assert.Equal("timeout", doc.FailedHosts[0].Category)
+	assert.Equal(3, doc.FailedHosts[0].Attempts)
+	assert.Equal("hosts.txt:2", doc.FailedHosts[0].Source)
+	assert.Equal(map[string]int{"timeout": 1}, doc.FailuresByCategory)
+}
+
+func TestRenderingEmptyJSONReportUsesEmptyLists(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(renderJSON(&buf, NewReport(make(map[Application]Metric))))
+
+	var doc map[string]interface{}
+	assert.Nil(json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal([]interface{}{}, doc["applications"])
+	assert.Equal([]interface{}{}, doc["failed_hosts"])
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// renderMarkdown writes r as GitHub flavored markdown, with a table of application versions
+// followed by any failed hosts.
+func renderMarkdown(w io.Writer, r *Report) error {
+	data := NewTemplateData(r)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## statusrep report\n\n")
+	fmt.Fprintf(&b, "%d/%d hosts reported", data.Hosts.Reported, data.Hosts.Total)
+	if !data.Run.Start.IsZero() {
+		fmt.Fprintf(&b, " at %s in %s", data.Run.Start.Format(time.RFC3339), data.Run.Duration.Truncate(time.Millisecond))
+	}
+	fmt.Fprintf(&b, ".\n\n")
+
+	var dimHeaders, dimAligns string
+	for _, d := range data.Dimensions {
+		dimHeaders += " " + escapeMarkdownCell(d) + " |"
+		dimAligns += " --- |"
+	}
+	fmt.Fprintf(&b, "| Application | Version |%s Requests | Successes | Errors | Success Rate | Error Rate |\n", dimHeaders)
+	fmt.Fprintf(&b, "| --- | --- |%s ---: | ---: | ---: | ---: | ---: |\n", dimAligns)
+	for _, row := range data.Applications {
+		var dims string
+		for _, d := range row.Group {
+			dims += " " + escapeMarkdownCell(d.Value) + " |"
+		}
+		fmt.Fprintf(&b, "| %s | %s |%s %s | %s | %s | %s | %s |\n",
+			escapeMarkdownCell(row.App.Name),
+			escapeMarkdownCell(row.App.Version),
+			dims,
+			formatCount(row.Metric.TotalRequestsCount),
+			formatCount(row.Metric.TotalSuccessCount),
+			formatCount(row.Metric.TotalErrorCount),
+			formatPercent(row.Metric.SuccessRate()),
+			formatPercent(row.Metric.ErrorRate()),
+		)
+	}
+
+	if len(data.FailedHosts) > 0 {
+		fmt.Fprintf(&b, "\n### Failed hosts\n\n")
+		fmt.Fprintf(&b, "| Host | URL | Category | Message |\n")
+		fmt.Fprintf(&b, "| --- | --- | --- | --- |\n")
+		for _, f := range data.FailedHosts {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+				escapeMarkdownCell(f.Host),
+				escapeMarkdownCell(f.URL),
+				escapeMarkdownCell(f.Category),
+				escapeMarkdownCell(f.Message),
+			)
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.Wrap(err, "unable to write markdown report")
+	}
+	return nil
+}
+
+// markdownEscaper escapes characters which would otherwise break out of a table cell or be
+// interpreted as markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`<`, `&lt;`,
+	`>`, `&gt;`,
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// escapeMarkdownCell makes s safe to place within a markdown table cell.
+func escapeMarkdownCell(s string) string {
+	return markdownEscaper.Replace(s)
+}
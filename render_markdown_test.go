@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRenderingMarkdownTable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "app|one", Version: "1.2.0", RequestsCount: 12345, SuccessCount: 12300, ErrorCount: 45}})
+	r.Add(Result{Host: Host{Name: "host2", URL: "http://root.com/host2/status"}, Err: withKind(ErrTimeout, ErrTimeout)})
+
+	var buf bytes.Buffer
+	assert.Nil(renderMarkdown(&buf, r))
+	out := buf.String()
+
+	assert.True(strings.Contains(out, "1/2 hosts reported."))
+	assert.True(strings.Contains(out, "| Application | Version | Requests | Successes | Errors | Success Rate | Error Rate |\n| --- | --- | ---: | ---: | ---: | ---: | ---: |\n"))
+	assert.True(strings.Contains(out, `| app\|one | 1.2.0 | 12,345 | 12,300 | 45 | 99.64% | 0.36% |`))
+	assert.True(strings.Contains(out, "### Failed hosts"))
+	assert.True(strings.Contains(out, "| host2 | http://root.com/host2/status | timeout |"))
+}
+
+func TestRenderingHTMLPage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "<script>app</script>", Version: "1.2.0", RequestsCount: 100, SuccessCount: 50}})
+	r.Add(Result{Status: HostStatus{Application: "app2", Version: "1.0.0", RequestsCount: 100, SuccessCount: 100}})
+	r.Add(Result{Host: Host{Name: "host3"}, Err: withKind(ErrDNS, ErrDNS)})
+
+	var buf bytes.Buffer
+	assert.Nil(renderHTML(&buf, r, 0.9))
+	out := buf.String()
+
+	assert.True(strings.HasPrefix(out, "<!DOCTYPE html>"))
+	assert.False(strings.Contains(out, "<script>app</script>"))
+	assert.True(strings.Contains(out, "&lt;script&gt;app&lt;/script&gt;"))
+	assert.Equal(1, strings.Count(out, `<tr class="warn">`))
+	assert.True(strings.Contains(out, `style="width: 50.00%"`))
+	assert.True(strings.Contains(out, "Appendix: failed hosts"))
+	assert.True(strings.Contains(out, "<td>host3</td>"))
+}
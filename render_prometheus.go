@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// promMetric is a single metric family in the Prometheus text exposition format.
+type promMetric struct {
+	name    string
+	help    string
+	kind    string
+	samples []promSample
+}
+
+// promSample is a single sample of a metric family.
+type promSample struct {
+	labels [][2]string
+	value  float64
+}
+
+// renderPrometheus writes r in the Prometheus text exposition format, for example to be picked
+// up by the node_exporter textfile collector.  Request totals are gauges rather than counters,
+// as they fall whenever a host restarts or leaves the inventory.
+func renderPrometheus(w io.Writer, r *Report) error {
+	labelNames, err := promLabelNames(r.Dimensions())
+	if err != nil {
+		return err
+	}
+
+	var (
+		requests  = promMetric{name: "statusrep_app_requests", kind: "gauge", help: "Requests reported by hosts running the application version, summed over their lifetime counters."}
+		successes = promMetric{name: "statusrep_app_successes", kind: "gauge", help: "Successful requests reported by hosts running the application version, summed over their lifetime counters."}
+		failures  = promMetric{name: "statusrep_app_errors", kind: "gauge", help: "Failed requests reported by hosts running the application version, summed over their lifetime counters."}
+		ratio     = promMetric{name: "statusrep_app_success_ratio", kind: "gauge", help: "Fraction of requests to the application version which succeeded."}
+		appHosts  = promMetric{name: "statusrep_app_hosts_reporting", kind: "gauge", help: "Hosts which reported running the application version."}
+
+		windowRequests = promMetric{name: "statusrep_app_window_requests", kind: "gauge", help: "Requests to the application version since the previous poll."}
+		windowRatio    = promMetric{name: "statusrep_app_window_success_ratio", kind: "gauge", help: "Fraction of requests to the application version since the previous poll which succeeded."}
+	)
+	for _, row := range r.Rows() {
+		labels := [][2]string{{"application", row.App.Name}, {"version", row.App.Version}}
+		for i, d := range row.Group {
+			labels = append(labels, [2]string{labelNames[i], d.Value})
+		}
+		requests.samples = append(requests.samples, promSample{labels, float64(row.Metric.TotalRequestsCount)})
+		successes.samples = append(successes.samples, promSample{labels, float64(row.Metric.TotalSuccessCount)})
+		failures.samples = append(failures.samples, promSample{labels, float64(row.Metric.TotalErrorCount)})
+		// a version without requests has no success ratio, rather than one of zero
+		if row.Metric.TotalRequestsCount > 0 {
+			ratio.samples = append(ratio.samples, promSample{labels, row.Metric.SuccessRate()})
+		}
+		appHosts.samples = append(appHosts.samples, promSample{labels, float64(row.Metric.HostCount)})
+		if row.Window != nil {
+			windowRequests.samples = append(windowRequests.samples, promSample{labels, float64(row.Window.TotalRequestsCount)})
+			if row.Window.TotalRequestsCount > 0 {
+				windowRatio.samples = append(windowRatio.samples, promSample{labels, row.Window.SuccessRate()})
+			}
+		}
+	}
+
+	failedByCategory := promMetric{name: "statusrep_hosts_failed", kind: "gauge", help: "Hosts which did not report a status, by error category."}
+	categories := r.FailureCategories()
+	names := make([]string, 0, len(categories))
+	for c := range categories {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for _, c := range names {
+		failedByCategory.samples = append(failedByCategory.samples, promSample{[][2]string{{"category", c}}, float64(categories[c])})
+	}
+
+	metrics := []promMetric{
+		requests, successes, failures, ratio, appHosts,
+	}
+	if len(r.Windows) > 0 {
+		metrics = append(metrics, windowRequests, windowRatio)
+	}
+	metrics = append(metrics, []promMetric{
+		{name: "statusrep_hosts", kind: "gauge", help: "Hosts which were polled.", samples: []promSample{{value: float64(r.HostsTotal)}}},
+		{name: "statusrep_hosts_reporting", kind: "gauge", help: "Hosts which reported a status.", samples: []promSample{{value: float64(r.HostsReported)}}},
+		failedByCategory,
+		{name: "statusrep_run_duration_seconds", kind: "gauge", help: "Duration of the run which produced these metrics.", samples: []promSample{{value: r.Duration.Seconds()}}},
+	}...)
+	if !r.Start.IsZero() {
+		metrics = append(metrics, promMetric{
+			name: "statusrep_run_timestamp_seconds", kind: "gauge", help: "Time the run which produced these metrics started, in seconds since the epoch.",
+			samples: []promSample{{value: float64(r.Start.UnixNano()) / 1e9}},
+		})
+	}
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, escapePromHelp(m.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range m.samples {
+			b.WriteString(m.name)
+			if len(s.labels) > 0 {
+				pairs := make([]string, len(s.labels))
+				for i, l := range s.labels {
+					pairs[i] = fmt.Sprintf(`%s="%s"`, l[0], escapePromLabel(l[1]))
+				}
+				b.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.Wrap(err, "unable to write prometheus metrics")
+	}
+	return nil
+}
+
+var (
+	promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	promHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// promLabelName makes a group dimension a valid label name, replacing invalid characters with
+// underscores.
+func promLabelName(s string) string {
+	name := []rune(s)
+	for i, c := range name {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || string(name) == "application" || string(name) == "version" {
+		return "group_" + string(name)
+	}
+	return string(name)
+}
+
+// promLabelNames returns the label name of every group dimension, rejecting dimensions which
+// would share a label name.
+func promLabelNames(dims []string) ([]string, error) {
+	names := make([]string, len(dims))
+	seen := make(map[string]string, len(dims))
+	for i, d := range dims {
+		names[i] = promLabelName(d)
+		if prev, ok := seen[names[i]]; ok {
+			return nil, errors.Errorf("group by dimensions '%s' and '%s' would both be the prometheus label '%s'", prev, d, names[i])
+		}
+		seen[names[i]] = d
+	}
+	return names, nil
+}
+
+// escapePromLabel escapes a label value for the text exposition format.
+func escapePromLabel(s string) string {
+	return promLabelEscaper.Replace(s)
+}
+
+// escapePromHelp escapes HELP text for the text exposition format.
+func escapePromHelp(s string) string {
+	return promHelpEscaper.Replace(s)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderingPrometheusMetrics(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Start = time.Unix(1559390400, 0)
+	r.Duration = 1500 * time.Millisecond
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.2.0", RequestsCount: 100, SuccessCount: 75, ErrorCount: 25}})
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.2.0", RequestsCount: 100, SuccessCount: 100}})
+	r.Add(Result{Host: Host{Name: "host3"}, Err: withKind(ErrTimeout, ErrTimeout)})
+
+	var buf bytes.Buffer
+	assert.Nil(renderPrometheus(&buf, r))
+	out := buf.String()
+
+	expected := []string{
+		"# HELP statusrep_app_requests Requests reported by hosts running the application version, summed over their lifetime counters.\n# TYPE statusrep_app_requests gauge\n",
+		`statusrep_app_requests{application="app1",version="1.2.0"} 200` + "\n",
+		`statusrep_app_successes{application="app1",version="1.2.0"} 175` + "\n",
+		`statusrep_app_errors{application="app1",version="1.2.0"} 25` + "\n",
+		`statusrep_app_success_ratio{application="app1",version="1.2.0"} 0.875` + "\n",
+		`statusrep_app_hosts_reporting{application="app1",version="1.2.0"} 2` + "\n",
+		"# TYPE statusrep_hosts gauge\nstatusrep_hosts 3\n",
+		"statusrep_hosts_reporting 2\n",
+		`statusrep_hosts_failed{category="timeout"} 1` + "\n",
+		"statusrep_run_duration_seconds 1.5\n",
+		"statusrep_run_timestamp_seconds 1.5593904e+09\n",
+	}
+	for _, e := range expected {
+		assert.True(strings.Contains(out, e), "missing %q", e)
+	}
+}
+
+func TestPrometheusLeavesOutRatiosWithoutRequests(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	busy := HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 100, SuccessCount: 90, ErrorCount: 10}
+	idle := HostStatus{Application: "app2", Version: "1.0.0", RequestsCount: 100, SuccessCount: 100}
+	r.Add(Result{Host: Host{Name: "host1"}, Status: busy})
+	r.Add(Result{Host: Host{Name: "host2"}, Status: idle})
+	r.Add(Result{Host: Host{Name: "host3"}, Status: HostStatus{Application: "app3", Version: "1.0.0"}})
+	prevAt := time.Unix(1559390400, 0)
+	r.AddWindow(Host{Name: "host1"}, HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 10, SuccessCount: 9, ErrorCount: 1}, prevAt, false)
+	r.AddWindow(Host{Name: "host2"}, HostStatus{Application: "app2", Version: "1.0.0"}, prevAt, false)
+
+	var buf bytes.Buffer
+	assert.Nil(renderPrometheus(&buf, r))
+	out := buf.String()
+
+	assert.Contains(out, `statusrep_app_window_requests{application="app2",version="1.0.0"} 0`+"\n")
+	assert.Contains(out, `statusrep_app_window_success_ratio{application="app1",version="1.0.0"} 0.9`+"\n")
+	assert.NotContains(out, `statusrep_app_window_success_ratio{application="app2"`)
+	assert.Contains(out, `statusrep_app_success_ratio{application="app2",version="1.0.0"} 1`+"\n")
+	assert.Contains(out, `statusrep_app_requests{application="app3",version="1.0.0"} 0`+"\n")
+	assert.NotContains(out, `statusrep_app_success_ratio{application="app3"`)
+}
+
+func TestEscapingPrometheusLabels(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "say \"hi\"\nback\\slash", Version: "1"}})
+
+	var buf bytes.Buffer
+	assert.Nil(renderPrometheus(&buf, r))
+	assert.True(strings.Contains(buf.String(), `{application="say \"hi\"\nback\\slash",version="1"}`))
+}
+
+func TestPrometheusRejectsCollidingLabelNames(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	names, err := promLabelNames([]string{"dc", "a-b", "9x"})
+	assert.Nil(err)
+	assert.Equal([]string{"dc", "a_b", "_x"}, names)
+
+	r := NewReport(make(map[Application]Metric))
+	r.GroupBy = []string{"application", "a-b", "a_b"}
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "1"}})
+
+	var buf bytes.Buffer
+	err = renderPrometheus(&buf, r)
+	if assert.NotNil(err) {
+		assert.Equal("group by dimensions 'a-b' and 'a_b' would both be the prometheus label 'a_b'", err.Error())
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	defaultColorMode = "auto"
+	defaultWarnBelow = 0.95
+
+	ansiRed   = "\x1b[31m"
+	ansiReset = "\x1b[0m"
+)
+
+// tableColumn is a single column of the table report.
+type tableColumn struct {
+	header     string
+	alignRight bool
+	value      func(row AppRow) string
+}
+
+var tableColumns = []tableColumn{
+	{"APPLICATION", false, func(row AppRow) string { return row.App.Name }},
+	{"VERSION", false, func(row AppRow) string { return row.App.Version }},
+	{"REQUESTS", true, func(row AppRow) string { return formatCount(row.Metric.TotalRequestsCount) }},
+	{"SUCCESSES", true, func(row AppRow) string { return formatCount(row.Metric.TotalSuccessCount) }},
+	{"ERRORS", true, func(row AppRow) string { return formatCount(row.Metric.TotalErrorCount) }},
+	{"SUCCESS RATE", true, func(row AppRow) string { return formatPercent(row.Metric.SuccessRate()) }},
+	{"ERROR RATE", true, func(row AppRow) string { return formatPercent(row.Metric.ErrorRate()) }},
+}
+
+// TableOptions controls how the table report is written.
+type TableOptions struct {
+	// Color enables ANSI colouring of rows whose success rate is below WarnBelow.
+	Color bool
+	// WarnBelow is the success rate under which rows are highlighted.
+	WarnBelow float64
+	// Previous is the report of the previous poll.  When set, columns showing the change in
+	// requests and success rate since that poll are added.
+	Previous *Report
+}
+
+// windowColumns are added to the table when any host was sampled on the previous poll.
+var windowColumns = []tableColumn{
+	{"WINDOW REQUESTS", true, func(row AppRow) string {
+		if row.Window == nil {
+			return "-"
+		}
+		return formatCount(row.Window.TotalRequestsCount)
+	}},
+	{"WINDOW RATE", true, func(row AppRow) string {
+		if row.Window == nil || row.Window.TotalRequestsCount == 0 {
+			return "-"
+		}
+		return formatPercent(row.Window.SuccessRate())
+	}},
+}
+
+// dimensionColumns returns a column for each dimension rows are grouped by.
+func dimensionColumns(dims []string) []tableColumn {
+	columns := make([]tableColumn, len(dims))
+	for i, d := range dims {
+		i := i
+		columns[i] = tableColumn{strings.ToUpper(d), false, func(row AppRow) string { return row.Group[i].Value }}
+	}
+	return columns
+}
+
+// deltaColumns returns the columns comparing each row with the same row of prev.
+func deltaColumns(prev *Report) []tableColumn {
+	prevRows := make(map[groupKey]Metric)
+	for _, row := range prev.Rows() {
+		prevRows[row.key()] = row.Metric
+	}
+
+	return []tableColumn{
+		{"+REQUESTS", true, func(row AppRow) string {
+			m, ok := prevRows[row.key()]
+			if !ok {
+				return "new"
+			}
+			return formatCountDelta(int64(row.Metric.TotalRequestsCount) - int64(m.TotalRequestsCount))
+		}},
+		{"TREND", false, func(row AppRow) string {
+			m, ok := prevRows[row.key()]
+			if !ok {
+				return ""
+			}
+			return formatRateTrend(row.Metric.SuccessRate() - m.SuccessRate())
+		}},
+	}
+}
+
+// renderTable writes the rows of r as a table with aligned columns, for reading in a terminal.
+func renderTable(w io.Writer, r *Report, opts TableOptions) error {
+	rows := r.Rows()
+	columns := append([]tableColumn{}, tableColumns[:2]...)
+	columns = append(columns, dimensionColumns(r.Dimensions())...)
+	columns = append(columns, tableColumns[2:]...)
+	if len(r.Windows) > 0 {
+		columns = append(columns, windowColumns...)
+	}
+	if opts.Previous != nil {
+		columns = append(columns, deltaColumns(opts.Previous)...)
+	}
+
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j, c := range columns {
+			cells[i][j] = c.value(row)
+		}
+	}
+
+	lines := formatTableLines(columns, cells)
+	if _, err := fmt.Fprintln(w, lines[0]); err != nil {
+		return errors.Wrap(err, "unable to write table header")
+	}
+	for i, row := range rows {
+		line := lines[i+1]
+		if opts.Color && row.Metric.SuccessRate() < opts.WarnBelow {
+			line = ansiRed + line + ansiReset
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return errors.Wrap(err, "unable to write table row")
+		}
+	}
+	return nil
+}
+
+// writeTableCells writes cells as a table with a header line naming the columns.
+func writeTableCells(w io.Writer, columns []tableColumn, cells [][]string) error {
+	for _, line := range formatTableLines(columns, cells) {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return errors.Wrap(err, "unable to write table")
+		}
+	}
+	return nil
+}
+
+// formatTableLines returns the header line followed by a line for every row of cells, with
+// every column padded to its widest cell.
+func formatTableLines(columns []tableColumn, cells [][]string) []string {
+	widths := make([]int, len(columns))
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.header
+		widths[i] = utf8.RuneCountInString(c.header)
+	}
+	for _, row := range cells {
+		for j, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	lines := []string{formatTableLine(columns, headers, widths)}
+	for _, row := range cells {
+		lines = append(lines, formatTableLine(columns, row, widths))
+	}
+	return lines
+}
+
+// formatTableLine pads each cell to the width of its column.
+func formatTableLine(columns []tableColumn, cells []string, widths []int) string {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+		if columns[i].alignRight {
+			padded[i] = pad + cell
+		} else {
+			padded[i] = cell + pad
+		}
+	}
+	return strings.TrimRight(strings.Join(padded, "  "), " ")
+}
+
+// formatCount formats n with a comma separating every three digits.
+func formatCount(n uint) string {
+	s := strconv.FormatUint(uint64(n), 10)
+	if len(s) <= 3 {
+		return s
+	}
+
+	var b strings.Builder
+	lead := len(s) % 3
+	if lead > 0 {
+		b.WriteString(s[:lead])
+	}
+	for i := lead; i < len(s); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(s[i : i+3])
+	}
+	return b.String()
+}
+
+// formatCountDelta formats a change in a count with its sign and thousands separators.
+func formatCountDelta(n int64) string {
+	switch {
+	case n > 0:
+		return "+" + formatCount(uint(n))
+	case n < 0:
+		return "-" + formatCount(uint(-n))
+	}
+	return "0"
+}
+
+// formatRateTrend formats a change in a rate as an arrow followed by the change in percentage
+// points, such as "↑ 0.25pp".
+func formatRateTrend(delta float64) string {
+	points := strconv.FormatFloat(math.Abs(delta*100), 'f', 2, 64) + "pp"
+	switch {
+	case points == "0.00pp":
+		return "="
+	case delta > 0:
+		return "↑ " + points
+	}
+	return "↓ " + points
+}
+
+// formatPercent formats a rate between 0 and 1 as a percentage.
+func formatPercent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 2, 64) + "%"
+}
+
+// ColorEnabled decides whether output written to f should be coloured.  mode is one of
+// "always", "never" or "auto", where auto colours only when f is a terminal.
+func ColorEnabled(mode string, f *os.File) (bool, error) {
+	switch strings.ToLower(mode) {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		return isTerminal(f), nil
+	}
+	return false, errors.Errorf("unknown color mode '%s', expected one of auto, always, never", mode)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormattingCounts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		n      uint
+		expOut string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{123456, "123,456"},
+		{1234567, "1,234,567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expOut, func(t *testing.T) {
+			assert.Equal(tt.expOut, formatCount(tt.n))
+		})
+	}
+}
+
+func tableTestReport() *Report {
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.2.0", RequestsCount: 12345, SuccessCount: 12300, ErrorCount: 45}})
+	r.Add(Result{Status: HostStatus{Application: "app2", Version: "1.10.0", RequestsCount: 200, SuccessCount: 150, ErrorCount: 50}})
+	return r
+}
+
+func TestRenderingTableAlignsColumns(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(renderTable(&buf, tableTestReport(), TableOptions{}))
+
+	expected := "" +
+		"APPLICATION  VERSION  REQUESTS  SUCCESSES  ERRORS  SUCCESS RATE  ERROR RATE\n" +
+		"app1         1.2.0      12,345     12,300      45        99.64%       0.36%\n" +
+		"app2         1.10.0        200        150      50        75.00%      25.00%\n"
+	assert.Equal(expected, buf.String())
+}
+
+func TestRenderingTableColorsRowsBelowThreshold(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(renderTable(&buf, tableTestReport(), TableOptions{Color: true, WarnBelow: 0.9}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 3)
+	assert.False(strings.Contains(lines[1], ansiRed))
+	assert.True(strings.HasPrefix(lines[2], ansiRed))
+	assert.True(strings.HasSuffix(lines[2], ansiReset))
+}
+
+func TestColorModes(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	color, err := ColorEnabled("always", nil)
+	assert.Nil(err)
+	assert.True(color)
+
+	color, err = ColorEnabled("never", nil)
+	assert.Nil(err)
+	assert.False(color)
+
+	_, err = ColorEnabled("sometimes", nil)
+	assert.NotNil(err)
+}
+
+func TestRenderingTableShowsChangesSincePreviousReport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	prev := NewReport(make(map[Application]Metric))
+	prev.Add(Result{Status: HostStatus{Application: "app1", Version: "1.2.0", RequestsCount: 10000, SuccessCount: 9900, ErrorCount: 100}})
+	prev.Add(Result{Status: HostStatus{Application: "app2", Version: "1.10.0", RequestsCount: 100, SuccessCount: 75, ErrorCount: 25}})
+	prev.Add(Result{Status: HostStatus{Application: "app3", Version: "0.1.0", RequestsCount: 10, SuccessCount: 10}})
+
+	var buf bytes.Buffer
+	assert.Nil(renderTable(&buf, tableTestReport(), TableOptions{Previous: prev}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 3)
+	assert.True(strings.HasSuffix(lines[0], "+REQUESTS  TREND"), lines[0])
+	assert.True(strings.HasSuffix(lines[1], "+2,345  ↑ 0.64pp"), lines[1])
+	assert.True(strings.HasSuffix(lines[2], "+100  ="), lines[2])
+}
+
+func TestFormattingRateTrends(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		delta  float64
+		expOut string
+	}{
+		{0, "="},
+		{0.00001, "="},
+		{0.0125, "↑ 1.25pp"},
+		{-0.5, "↓ 50.00pp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expOut, func(t *testing.T) {
+			assert.Equal(tt.expOut, formatRateTrend(tt.delta))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the data model given to report templates.  Fields are only ever added to it,
+// so that existing templates keep working between versions.
+type TemplateData struct {
+	// Applications holds a row per application version, ordered by the report's sort keys.
+	Applications []AppRow
+	// Dimensions lists the dimensions other than application and version rows are grouped by,
+	// in the order of the Group of each row.
+	Dimensions []string
+	// FailedHosts lists every host which did not report a status, ordered by host.
+	FailedHosts []FailedHost
+	// FailuresByCategory is the number of failed hosts in each error category.
+	FailuresByCategory map[string]int
+	// Hosts summarizes how many hosts reported a status.
+	Hosts TemplateHosts
+	// Run describes the run which produced the report.
+	Run TemplateRun
+}
+
+// TemplateHosts summarizes how many hosts reported a status.
+type TemplateHosts struct {
+	Total    int
+	Reported int
+	Failed   int
+	Coverage float64
+	Attempts int
+}
+
+// TemplateRun describes the run which produced a report.
+type TemplateRun struct {
+	Version  string
+	RootURL  string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// NewTemplateData creates the template data model of r.
+func NewTemplateData(r *Report) TemplateData {
+	return TemplateData{
+		Applications:       r.Rows(),
+		Dimensions:         r.Dimensions(),
+		FailedHosts:        r.SortedFailedHosts(),
+		FailuresByCategory: r.FailureCategories(),
+		Hosts: TemplateHosts{
+			Total:    r.HostsTotal,
+			Reported: r.HostsReported,
+			Failed:   len(r.FailedHosts),
+			Coverage: float64(r.Coverage()),
+			Attempts: r.Attempts,
+		},
+		Run: TemplateRun{
+			Version:  r.Version,
+			RootURL:  r.RootURL,
+			Start:    r.Start,
+			Duration: r.Duration,
+		},
+	}
+}
+
+// templateFuncs are the helper functions available to report templates.
+var templateFuncs = template.FuncMap{
+	// percent formats a rate between 0 and 1 as a percentage, such as 99.50%.
+	"percent": formatPercent,
+	// count formats a number with thousands separators.
+	"count": formatCount,
+	// duration formats a duration rounded to the millisecond.
+	"duration": func(d time.Duration) string { return d.Truncate(time.Millisecond).String() },
+	// sortBy returns rows ordered by comma separated sort keys, as accepted by --sort.
+	"sortBy": func(keys string, rows []AppRow) ([]AppRow, error) {
+		parsed, err := ParseSortKeys(strings.Split(keys, ","))
+		if err != nil {
+			return nil, err
+		}
+		sorted := make([]AppRow, len(rows))
+		copy(sorted, rows)
+		SortRows(sorted, parsed)
+		return sorted, nil
+	},
+	// padRight pads s with spaces on the right to width characters.
+	"padRight": func(width int, s string) string { return padString(s, width, false) },
+	// padLeft pads s with spaces on the left to width characters.
+	"padLeft": func(width int, s string) string { return padString(s, width, true) },
+}
+
+// padString pads s with spaces up to width characters, on the left when left is true.
+func padString(s string, width int, left bool) string {
+	n := width - len([]rune(s))
+	if n <= 0 {
+		return s
+	}
+	if left {
+		return strings.Repeat(" ", n) + s
+	}
+	return s + strings.Repeat(" ", n)
+}
+
+// ParseReportTemplate parses the text/template at path, with the report helper functions.
+func ParseReportTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse template '%s'", path)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate executes tmpl with the template data model of r.
+func renderTemplate(w io.Writer, r *Report, tmpl *template.Template) error {
+	if err := tmpl.Execute(w, NewTemplateData(r)); err != nil {
+		return errors.Wrapf(err, "unable to execute template '%s'", tmpl.Name())
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestTemplate writes a template to a temporary file and returns its path, along with a
+// func removing it.
+func writeTestTemplate(t *testing.T, text string) (string, func()) {
+	dir, err := ioutil.TempDir("", "statusrep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "report.tmpl")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestRenderingTemplate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Version = "0.1.0"
+	r.Duration = 1234567 * time.Microsecond
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.2.0", RequestsCount: 12345, SuccessCount: 12300}})
+	r.Add(Result{Status: HostStatus{Application: "app2", Version: "1.10.0", RequestsCount: 200, SuccessCount: 150}})
+	r.Add(Result{Host: Host{Name: "host3"}, Err: withKind(ErrTimeout, ErrTimeout)})
+
+	tests := []struct {
+		name   string
+		text   string
+		expOut string
+	}{
+		{
+			"run_and_hosts",
+			`{{.Run.Version}} {{.Hosts.Reported}}/{{.Hosts.Total}} in {{duration .Run.Duration}}`,
+			"0.1.0 2/3 in 1.234s",
+		},
+		{
+			"applications",
+			`{{range .Applications}}{{.App.Name}} {{count .Metric.TotalRequestsCount}} {{percent .Metric.SuccessRate}};{{end}}`,
+			"app1 12,345 99.64%;app2 200 75.00%;",
+		},
+		{
+			"sorted_and_padded",
+			`{{range sortBy "success-rate" .Applications}}[{{padRight 6 .App.Name}}|{{padLeft 7 .App.Version}}]{{end}}`,
+			"[app2  | 1.10.0][app1  |  1.2.0]",
+		},
+		{
+			"failed_hosts",
+			`{{range .FailedHosts}}{{.Host}}:{{.Category}}{{end}} {{index .FailuresByCategory "timeout"}}`,
+			"host3:timeout 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := writeTestTemplate(t, tt.text)
+			defer cleanup()
+
+			tmpl, err := ParseReportTemplate(path)
+			assert.Nil(err)
+
+			var buf bytes.Buffer
+			assert.Nil(renderTemplate(&buf, r, tmpl))
+			assert.Equal(tt.expOut, buf.String())
+		})
+	}
+}
+
+func TestParsingInvalidTemplateFails(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	path, cleanup := writeTestTemplate(t, `{{range .Applications}}`)
+	defer cleanup()
+
+	_, err := ParseReportTemplate(path)
+	assert.NotNil(err)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FailedHost is a host whose status could not be retrieved.
+type FailedHost struct {
+	// Host is the host as given in the hosts file.
+	Host string
+	// URL is the status URL which was queried, if one could be created.
+	URL string
+	// Category is the error category of the failure, as given by ErrorCategory.
+	Category string
+	// Message describes the failure.
+	Message string
+	// Attempts is the number of status requests made to the host.
+	Attempts int
+	// Source is where the host was read from, as given by Host.Origin.
+	Source string
+}
+
+// AppRow is the metrics of a single application version, as a row of the report.  When the
+// report is grouped by other dimensions, App holds only the parts of the application version
+// grouped by and Group holds the value of every other dimension.
+type AppRow struct {
+	App    Application
+	Group  []Dimension
+	Metric Metric
+	// Window holds the counts since the previous poll, or nil when no host running the
+	// application version was sampled on the previous poll.
+	Window *Metric
+}
+
+// Report is the aggregated outcome of polling every host in a run.
+type Report struct {
+	// Apps holds the metrics of every application version reported by a host.
+	Apps map[Application]Metric
+	// FailedHosts lists every host which did not report a status.
+	FailedHosts []FailedHost
+	// HostsTotal is the number of hosts which were to be polled.
+	HostsTotal int
+	// HostsReported is the number of hosts which reported a status.
+	HostsReported int
+	// Attempts is the number of status requests made across all hosts.
+	Attempts int
+	// Retries is the number of status requests made after the first to any host.  Hosts which
+	// were never requested, such as those without a valid URL, made no retries.
+	Retries int
+
+	// Windows holds the counts of every application version since the previous poll, for the
+	// hosts which were sampled on the previous poll.
+	Windows map[Application]Metric
+	// WindowStart is the time of the earliest previous sample counted in Windows.
+	WindowStart time.Time
+	// CounterResets is the number of hosts whose counters were reset since the previous poll.
+	CounterResets int
+
+	// Start is the time the run started.
+	Start time.Time
+	// Duration is how long the run took.
+	Duration time.Duration
+	// Version is the statusrep version which produced the report.
+	Version string
+	// RootURL is the root URL host status pages were requested from.
+	RootURL string
+	// Sort orders the rows of the report.  Rows are ordered by name and version when empty.
+	Sort []SortKey
+	// GroupBy lists the dimensions rows are aggregated by, as parsed by ParseGroupBy.  Rows are
+	// aggregated by application and version when empty.  It must be set before the first Add.
+	GroupBy []string
+
+	// groups and groupWindows hold the metrics of every row, as Apps and Windows do, when
+	// GroupBy is set.
+	groups       map[groupKey]Metric
+	groupWindows map[groupKey]Metric
+}
+
+// NewReport creates an empty Report which aggregates into apps.
+func NewReport(apps map[Application]Metric) *Report {
+	return &Report{
+		Apps:         apps,
+		Windows:      make(map[Application]Metric),
+		groups:       make(map[groupKey]Metric),
+		groupWindows: make(map[groupKey]Metric),
+	}
+}
+
+// Add records the outcome of polling a single host.  Statuses which fail or which do not name
+// an application are recorded as failed hosts rather than counted against an application.
+func (r *Report) Add(res Result) {
+	err := res.Err
+	if err == nil && res.Status.Application == "" {
+		err = ErrEmptyStatus
+	}
+	if err != nil {
+		r.AddFailure(res.Host, err, res.Attempts)
+		return
+	}
+
+	r.HostsTotal++
+	r.addAttempts(res.Attempts)
+
+	r.HostsReported++
+	IncrementCounters(r.Apps, res.Status)
+	if len(r.GroupBy) > 0 {
+		key := r.groupKey(res.Host, res.Status)
+		r.groups[key] = r.groups[key].AddStatus(res.Status)
+	}
+}
+
+// AddWindow records the counts host h reported since its previous sample at prevAt.
+func (r *Report) AddWindow(h Host, status HostStatus, prevAt time.Time, reset bool) {
+	if reset {
+		r.CounterResets++
+	}
+	if r.WindowStart.IsZero() || prevAt.Before(r.WindowStart) {
+		r.WindowStart = prevAt
+	}
+	IncrementCounters(r.Windows, status)
+	if len(r.GroupBy) > 0 {
+		key := r.groupKey(h, status)
+		r.groupWindows[key] = r.groupWindows[key].AddStatus(status)
+	}
+}
+
+// groupKey returns the key of the row a host's status is aggregated into.
+func (r *Report) groupKey(h Host, status HostStatus) groupKey {
+	var (
+		key  groupKey
+		dims []string
+	)
+	for _, d := range r.GroupBy {
+		switch d {
+		case "application":
+			key.App.Name = status.Application
+		case "version":
+			key.App.Version = status.Version
+		default:
+			dims = append(dims, h.Attribute(d))
+		}
+	}
+	key.Dims = strings.Join(dims, groupSeparator)
+	return key
+}
+
+// Dimensions returns the dimensions rows are grouped by other than application and version.
+func (r *Report) Dimensions() []string {
+	var dims []string
+	for _, d := range r.GroupBy {
+		if d != "application" && d != "version" {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
+
+// AddFailure records a host which did not report a status because of err.  The host counts
+// towards HostsTotal, as it does when recorded by Add.
+func (r *Report) AddFailure(h Host, err error, attempts int) {
+	r.HostsTotal++
+	r.addAttempts(attempts)
+	r.FailedHosts = append(r.FailedHosts, FailedHost{
+		Host:     h.Name,
+		URL:      h.URL,
+		Category: ErrorCategory(err),
+		Message:  err.Error(),
+		Attempts: attempts,
+		Source:   h.Origin(),
+	})
+}
+
+// addAttempts counts the status requests made to a single host.
+func (r *Report) addAttempts(attempts int) {
+	r.Attempts += attempts
+	if attempts > 1 {
+		r.Retries += attempts - 1
+	}
+}
+
+// Rows returns a row for every application version, or every group when the report is grouped,
+// ordered by the report's sort keys.
+func (r *Report) Rows() []AppRow {
+	if len(r.GroupBy) > 0 {
+		return r.groupRows()
+	}
+
+	rows := make([]AppRow, 0, len(r.Apps))
+	for app, m := range r.Apps {
+		row := AppRow{App: app, Metric: m}
+		if w, ok := r.Windows[app]; ok {
+			row.Window = &w
+		}
+		rows = append(rows, row)
+	}
+	SortRows(rows, r.Sort)
+	return rows
+}
+
+// groupRows returns a row for every group, ordered by the report's sort keys.
+func (r *Report) groupRows() []AppRow {
+	dims := r.Dimensions()
+	rows := make([]AppRow, 0, len(r.groups))
+	for key, m := range r.groups {
+		row := AppRow{App: key.App, Metric: m}
+		if len(dims) > 0 {
+			values := strings.Split(key.Dims, groupSeparator)
+			for i, d := range dims {
+				row.Group = append(row.Group, Dimension{Name: d, Value: values[i]})
+			}
+		}
+		if w, ok := r.groupWindows[key]; ok {
+			row.Window = &w
+		}
+		rows = append(rows, row)
+	}
+	SortRows(rows, r.Sort)
+	return rows
+}
+
+// key returns the key identifying the row within its report.
+func (row AppRow) key() groupKey {
+	values := make([]string, len(row.Group))
+	for i, d := range row.Group {
+		values[i] = d.Value
+	}
+	return groupKey{App: row.App, Dims: strings.Join(values, groupSeparator)}
+}
+
+// SortedFailedHosts returns the failed hosts ordered by host.
+func (r *Report) SortedFailedHosts() []FailedHost {
+	sorted := make([]FailedHost, len(r.FailedHosts))
+	copy(sorted, r.FailedHosts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Host < sorted[j].Host })
+	return sorted
+}
+
+// FailureCategories returns the number of failed hosts in each error category.
+func (r *Report) FailureCategories() map[string]int {
+	categories := make(map[string]int)
+	for _, f := range r.FailedHosts {
+		categories[f.Category]++
+	}
+	return categories
+}
+
+// Coverage returns the fraction of hosts which reported a status.
+func (r *Report) Coverage() float32 {
+	if r.HostsTotal == 0 {
+		return 0
+	}
+	return float32(r.HostsReported) / float32(r.HostsTotal)
+}
+
+// writeSummary writes every failed host and the number of failures in each error category,
+// followed by how many hosts reported a status.
+func writeSummary(w io.Writer, r *Report) {
+	writeFailedHosts(w, r.SortedFailedHosts())
+	writeFailureSummary(w, r.FailureCategories())
+
+	if _, err := fmt.Fprintf(w, "\n%d/%d hosts reported\n", r.HostsReported, r.HostsTotal); err != nil {
+		log.WithError(err).Error("invalid printer format")
+	}
+	if r.CounterResets > 0 {
+		if _, err := fmt.Fprintf(w, "%d hosts reset their counters since the previous poll\n", r.CounterResets); err != nil {
+			log.WithError(err).Error("invalid printer format")
+		}
+	}
+}
+
+// writeFailedHosts writes the host, URL, error category and message of every failed host.
+func writeFailedHosts(w io.Writer, failed []FailedHost) {
+	if len(failed) == 0 {
+		return
+	}
+
+	if _, err := fmt.Fprintf(w, "\nfailed hosts:\n"); err != nil {
+		log.WithError(err).Error("invalid printer format")
+	}
+	for _, f := range failed {
+		if _, err := fmt.Fprintf(w, "%s,%s,%s,%q\n", f.Host, f.URL, f.Category, f.Message); err != nil {
+			log.WithError(err).Error("invalid printer format")
+		}
+	}
+}
+
+// writeFailureSummary writes the number of failed hosts in each error category.
+func writeFailureSummary(w io.Writer, failures map[string]int) {
+	if len(failures) == 0 {
+		return
+	}
+
+	categories := make([]string, 0, len(failures))
+	for c := range failures {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+
+	if _, err := fmt.Fprintf(w, "\nfailures by category:\n"); err != nil {
+		log.WithError(err).Error("invalid printer format")
+	}
+	for _, c := range categories {
+		if _, err := fmt.Fprintf(w, "%s,%d\n", c, failures[c]); err != nil {
+			log.WithError(err).Error("invalid printer format")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReportCountsSuccessfulStatuses(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "v1", RequestsCount: 10, SuccessCount: 9}, Attempts: 1})
+	r.Add(Result{Host: Host{Name: "host2"}, Status: HostStatus{Application: "app1", Version: "v1", RequestsCount: 5, SuccessCount: 5}, Attempts: 2})
+
+	assert.Equal(2, r.HostsTotal)
+	assert.Equal(2, r.HostsReported)
+	assert.Equal(3, r.Attempts)
+	assert.Empty(r.FailedHosts)
+	assert.Equal(Metric{TotalRequestsCount: 15, TotalSuccessCount: 14, HostCount: 2}, r.Apps[Application{Name: "app1", Version: "v1"}])
+}
+
+func TestReportRecordsFailedHostsWithoutPollutingApps(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name        string
+		res         Result
+		expCategory string
+	}{
+		{"request_error", Result{Host: Host{Name: "host1", URL: "http://root.com/host1/status"}, Err: errors.Wrap(&StatusError{Code: 500}, "get")}, "http_status"},
+		{"empty_status", Result{Host: Host{Name: "host2", URL: "http://root.com/host2/status"}}, "empty_status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReport(make(map[Application]Metric))
+			r.Add(tt.res)
+
+			assert.Empty(r.Apps)
+			assert.Equal(1, r.HostsTotal)
+			assert.Equal(0, r.HostsReported)
+			assert.Len(r.FailedHosts, 1)
+			assert.Equal(tt.res.Host.Name, r.FailedHosts[0].Host)
+			assert.Equal(tt.res.Host.URL, r.FailedHosts[0].URL)
+			assert.Equal(tt.expCategory, r.FailedHosts[0].Category)
+			assert.Equal(map[string]int{tt.expCategory: 1}, r.FailureCategories())
+		})
+	}
+}
+
+func TestReportCountsFailuresRecordedDirectly(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "v1"}, Attempts: 1})
+	r.AddFailure(Host{Name: "host2"}, withKind(ErrInvalidURL, ErrInvalidURL), 0)
+	r.Add(Result{Host: Host{Name: "host3"}, Err: withKind(ErrTimeout, ErrTimeout), Attempts: 3})
+
+	assert.Equal(3, r.HostsTotal)
+	assert.Equal(1, r.HostsReported)
+	assert.Equal(4, r.Attempts)
+	assert.Equal(2, r.Retries)
+	assert.Equal(float32(1)/3, r.Coverage())
+}
+
+func TestWritingSummaryIncludesFailedHostsAndCoverage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "v1", RequestsCount: 4, SuccessCount: 3}})
+	r.Add(Result{Host: Host{Name: "host2", URL: "http://root.com/host2/status"}, Err: withKind(ErrTimeout, errors.New("too slow"))})
+
+	var buf bytes.Buffer
+	writeSummary(&buf, r)
+	out := buf.String()
+
+	assert.True(strings.Contains(out, "host2,http://root.com/host2/status,timeout,"))
+	assert.True(strings.Contains(out, "timeout,1\n"))
+	assert.True(strings.Contains(out, "1/2 hosts reported"))
+}
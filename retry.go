@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	defaultMaxAttempts   = 3
+	defaultRetryDelay    = 250 * time.Millisecond
+	defaultRetryMaxDelay = 5 * time.Second
+
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// RetryBudget limits the total number of retries made during a run, so that a widespread
+// outage does not multiply the load placed on every host.
+type RetryBudget struct {
+	remaining int64
+}
+
+// NewRetryBudget creates a RetryBudget allowing n retries.
+func NewRetryBudget(n int) *RetryBudget {
+	return &RetryBudget{remaining: int64(n)}
+}
+
+// take reserves a single retry from the budget, returning false when the budget is exhausted.
+func (b *RetryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	return atomic.AddInt64(&b.remaining, -1) >= 0
+}
+
+// RetryPolicy decides whether, and after how long, a failed status request is attempted again.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts for a single host, including the first.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry.  It is doubled for each following retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts.  A host asking to wait longer is not retried.
+	MaxDelay time.Duration
+	// Budget is shared by all hosts of a run.  A nil Budget allows unlimited retries.
+	Budget *RetryBudget
+}
+
+// Do calls fn until it succeeds, fails with an error which is not retryable, runs out of attempts,
+// is asked to wait longer than MaxDelay or the retry budget is exhausted.
+// The number of attempts made and the last error are returned, and desc describes the operation
+// in debug logs.
+func (p *RetryPolicy) Do(ctx context.Context, desc string, fn func() error) (int, error) {
+	var attempt int
+	for {
+		attempt++
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+
+		retryable, retryAfter := IsRetryable(err)
+		if !retryable || attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return attempt, err
+		}
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			log.WithField("attempt", attempt).Debugf("%s asked to wait %s, longer than the max delay, giving up", desc, retryAfter)
+			return attempt, err
+		}
+		if !p.Budget.take() {
+			log.WithField("attempt", attempt).Debugf("retry budget exhausted, giving up on %s", desc)
+			return attempt, err
+		}
+
+		delay := p.backoff(attempt, retryAfter)
+		log.WithError(err).WithFields(log.Fields{
+			"attempt": attempt,
+			"delay":   delay,
+		}).Debugf("retrying %s", desc)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns the delay before the attempt following attempt.  The delay grows exponentially
+// from BaseDelay up to MaxDelay, with jitter applied so that hosts failing together do not retry
+// together.  A delay requested by the host is honoured in full, and Do gives up rather than
+// retry sooner when it is beyond MaxDelay.
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// keep half of the delay and randomize the other half
+	half := int64(delay / 2)
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return time.Duration(half + jitterRand.Int63n(half+1))
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying, along with any delay
+// the host asked for before the next attempt.  Connection failures, truncated bodies, 5xx
+// responses and 429 responses are retryable.
+func IsRetryable(err error) (bool, time.Duration) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500 {
+			return true, statusErr.RetryAfter
+		}
+		return false, 0
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true, 0
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true, 0
+	}
+	return false, 0
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either in seconds or as an
+// HTTP date.  Zero is returned when the value is missing or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
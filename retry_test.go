@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryableErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name         string
+		err          error
+		expRetryable bool
+	}{
+		{"service_unavailable", &StatusError{Code: http.StatusServiceUnavailable}, true},
+		{"too_many_requests", &StatusError{Code: http.StatusTooManyRequests}, true},
+		{"not_found", &StatusError{Code: http.StatusNotFound}, false},
+		{"truncated_body", errors.Wrap(io.ErrUnexpectedEOF, "unable to read response body"), true},
+		{"unmarshal", errors.Wrap(ErrUnmarshal, "bad body"), false},
+		{"cancelled", errors.Wrap(context.Canceled, "request"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, _ := IsRetryable(tt.err)
+			assert.Equal(tt.expRetryable, retryable)
+		})
+	}
+}
+
+func TestRetryingHostStatusUntilSuccess(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, err := fmt.Fprintf(w, `{"requests_count": 10}`)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	host := Host{URL: ts.URL}
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}
+	var status HostStatus
+	attempts, err := policy.Do(context.Background(), "test", func() error {
+		var err error
+		status, err = host.RequestHostStatus(context.Background())
+		return err
+	})
+	assert.Nil(err)
+	assert.Equal(3, attempts)
+	assert.Equal(uint(10), status.RequestsCount)
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond}
+	attempts, err := policy.Do(context.Background(), "test", func() error {
+		return &StatusError{Code: http.StatusBadGateway}
+	})
+	assert.NotNil(err)
+	assert.Equal(4, attempts)
+}
+
+func TestRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond}
+	attempts, err := policy.Do(context.Background(), "test", func() error {
+		return &StatusError{Code: http.StatusNotFound}
+	})
+	assert.NotNil(err)
+	assert.Equal(1, attempts)
+}
+
+func TestRetryBudgetIsSharedAcrossCalls(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Millisecond, Budget: NewRetryBudget(3)}
+	fail := func() error { return &StatusError{Code: http.StatusInternalServerError} }
+
+	attempts, _ := policy.Do(context.Background(), "first", fail)
+	assert.Equal(4, attempts)
+	attempts, _ = policy.Do(context.Background(), "second", fail)
+	assert.Equal(1, attempts)
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt < 20; attempt++ {
+		delay := policy.backoff(attempt, 0)
+		assert.True(delay <= time.Second, "attempt %d delay %s", attempt, delay)
+		assert.True(delay > 0)
+	}
+	assert.Equal(500*time.Millisecond, policy.backoff(1, 500*time.Millisecond))
+	assert.Equal(time.Minute, policy.backoff(1, time.Minute))
+}
+
+func TestRetryGivesUpWhenAskedToWaitBeyondMaxDelay(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	start := time.Now()
+	attempts, err := policy.Do(context.Background(), "test", func() error {
+		return &StatusError{Code: http.StatusTooManyRequests, RetryAfter: time.Minute}
+	})
+	assert.NotNil(err)
+	assert.Equal(1, attempts)
+	assert.True(time.Since(start) < time.Second)
+
+	attempts, err = policy.Do(context.Background(), "test", func() error {
+		return &StatusError{Code: http.StatusServiceUnavailable, RetryAfter: time.Millisecond}
+	})
+	assert.NotNil(err)
+	assert.Equal(4, attempts)
+}
+
+func TestParsingRetryAfter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expDelay time.Duration
+	}{
+		{"seconds", "3", 3 * time.Second},
+		{"http_date", "Sat, 01 Jun 2019 12:00:10 GMT", 10 * time.Second},
+		{"past_date", "Sat, 01 Jun 2019 11:00:00 GMT", 0},
+		{"empty", "", 0},
+		{"invalid", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(tt.expDelay, parseRetryAfter(tt.value, now))
+		})
+	}
+}
+
+func TestRetryAfterHeaderIsReturnedWithStatusError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	host := Host{URL: ts.URL}
+	_, err := host.RequestHostStatus(context.Background())
+	retryable, delay := IsRetryable(err)
+	assert.True(retryable)
+	assert.Equal(2*time.Second, delay)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sample is the lifetime counters a host reported on a single poll.  Comparing a host's sample
+// with the one from its previous poll gives the counts over the interval between the polls.
+type Sample struct {
+	Application   string    `json:"application"`
+	Version       string    `json:"version"`
+	RequestsCount uint      `json:"requests_count"`
+	SuccessCount  uint      `json:"success_count"`
+	ErrorCount    uint      `json:"error_count"`
+	At            time.Time `json:"at"`
+}
+
+// Samples holds the latest sample of every host, keyed by the status URL of the host so that
+// hosts of the same name on different ports are kept apart.
+type Samples map[string]Sample
+
+// NewSample creates the sample of a status reported at the given time.
+func NewSample(status HostStatus, at time.Time) Sample {
+	return Sample{
+		Application:   status.Application,
+		Version:       status.Version,
+		RequestsCount: status.RequestsCount,
+		SuccessCount:  status.SuccessCount,
+		ErrorCount:    status.ErrorCount,
+		At:            at,
+	}
+}
+
+// Since returns the counts of s accumulated since prev, as a status.  The counters are taken to
+// have been reset, for example by the process restarting, when any of them went backwards or
+// when the application version changed.  All of the counts of s are then within the interval.
+func (s Sample) Since(prev Sample) (status HostStatus, reset bool) {
+	status = HostStatus{Application: s.Application, Version: s.Version}
+	reset = s.Application != prev.Application || s.Version != prev.Version ||
+		s.RequestsCount < prev.RequestsCount || s.SuccessCount < prev.SuccessCount || s.ErrorCount < prev.ErrorCount
+	if reset {
+		status.RequestsCount = s.RequestsCount
+		status.SuccessCount = s.SuccessCount
+		status.ErrorCount = s.ErrorCount
+		return status, true
+	}
+
+	status.RequestsCount = s.RequestsCount - prev.RequestsCount
+	status.SuccessCount = s.SuccessCount - prev.SuccessCount
+	status.ErrorCount = s.ErrorCount - prev.ErrorCount
+	return status, false
+}
+
+// LoadSamples reads the samples saved to path by SaveSamples.  No samples are returned when the
+// file does not exist yet.
+func LoadSamples(path string) (Samples, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Samples{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open state file '%s'", path)
+	}
+	defer f.Close()
+
+	samples := Samples{}
+	if err := json.NewDecoder(f).Decode(&samples); err != nil {
+		return nil, errors.Wrapf(err, "unable to read state file '%s'", path)
+	}
+	return samples, nil
+}
+
+// SaveSamples atomically replaces the contents of path with samples, creating the directory of
+// path when necessary.
+func SaveSamples(path string, samples Samples) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "unable to create state directory '%s'", filepath.Dir(path))
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(samples); err != nil {
+			return errors.Wrap(err, "unable to encode samples")
+		}
+		return nil
+	})
+}
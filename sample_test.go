@@ -0,0 +1,96 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSampleCountsSincePreviousSample(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	prev := Sample{Application: "app1", Version: "1.0.0", RequestsCount: 100, SuccessCount: 90, ErrorCount: 10}
+
+	tests := []struct {
+		name      string
+		sample    Sample
+		expStatus HostStatus
+		expReset  bool
+	}{
+		{
+			"counters increase",
+			Sample{Application: "app1", Version: "1.0.0", RequestsCount: 150, SuccessCount: 130, ErrorCount: 20},
+			HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 50, SuccessCount: 40, ErrorCount: 10},
+			false,
+		},
+		{
+			"counters unchanged",
+			prev,
+			HostStatus{Application: "app1", Version: "1.0.0"},
+			false,
+		},
+		{
+			"process restarted",
+			Sample{Application: "app1", Version: "1.0.0", RequestsCount: 20, SuccessCount: 19, ErrorCount: 1},
+			HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 20, SuccessCount: 19, ErrorCount: 1},
+			true,
+		},
+		{
+			"only error counter went backwards",
+			Sample{Application: "app1", Version: "1.0.0", RequestsCount: 200, SuccessCount: 195, ErrorCount: 5},
+			HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 200, SuccessCount: 195, ErrorCount: 5},
+			true,
+		},
+		{
+			"new version deployed",
+			Sample{Application: "app1", Version: "1.1.0", RequestsCount: 150, SuccessCount: 150},
+			HostStatus{Application: "app1", Version: "1.1.0", RequestsCount: 150, SuccessCount: 150},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reset := tt.sample.Since(prev)
+			assert.Equal(tt.expStatus, status)
+			assert.Equal(tt.expReset, reset)
+		})
+	}
+}
+
+func TestSavingAndLoadingSamples(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	samples, err := LoadSamples(path)
+	assert.Nil(err)
+	assert.Empty(samples)
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	samples = Samples{"host1": {Application: "app1", Version: "1.0.0", RequestsCount: 10, SuccessCount: 9, ErrorCount: 1, At: at}}
+	assert.Nil(SaveSamples(path, samples))
+
+	loaded, err := LoadSamples(path)
+	assert.Nil(err)
+	assert.Equal(samples, loaded)
+
+	assert.Nil(ioutil.WriteFile(path, []byte("not json"), 0644))
+	_, err = LoadSamples(path)
+	assert.NotNil(err)
+
+	// the state directory is created on the first save
+	path = filepath.Join(dir, "state", "samples.json")
+	assert.Nil(SaveSamples(path, samples))
+	loaded, err = LoadSamples(path)
+	assert.Nil(err)
+	assert.Equal(samples, loaded)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+)
+
+// Result is the outcome of polling a single host.
+type Result struct {
+	Host   Host
+	Status HostStatus
+	Err    error
+	// Attempts is the number of status requests made to the host.
+	Attempts int
+}
+
+// Scheduler polls hosts from a fixed pool of workers, so that the number of open connections
+// stays bounded no matter how many hosts are given.
+type Scheduler struct {
+	// Concurrency is the number of workers polling hosts at the same time.
+	Concurrency int
+	// MaxInflightPerRoot limits outstanding requests against a single root, the scheme and
+	// host portion of a status URL.  Zero disables the limit.
+	MaxInflightPerRoot int
+}
+
+// Run polls every host with poll and streams each Result as soon as it completes.  The
+// returned channel is closed once all hosts have been polled.
+//
+// Hosts are queued separately for every root, and a host only takes a worker once its root
+// is below MaxInflightPerRoot, so that a busy root never holds workers other roots could use.
+func (s *Scheduler) Run(hosts []Host, poll func(Host) Result) <-chan Result {
+	results := make(chan Result)
+
+	workers := s.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+
+	var roots []string
+	queues := make(map[string][]Host)
+	for _, h := range hosts {
+		root := rootOf(h.URL)
+		if _, ok := queues[root]; !ok {
+			roots = append(roots, root)
+		}
+		queues[root] = append(queues[root], h)
+	}
+
+	var wg sync.WaitGroup
+	for _, root := range roots {
+		wg.Add(1)
+		go func(queue []Host) {
+			defer wg.Done()
+			var inflight chan struct{}
+			if s.MaxInflightPerRoot > 0 {
+				inflight = make(chan struct{}, s.MaxInflightPerRoot)
+			}
+
+			var polls sync.WaitGroup
+			for _, h := range queue {
+				if inflight != nil {
+					inflight <- struct{}{}
+				}
+				slots <- struct{}{}
+				polls.Add(1)
+				go func(h Host) {
+					defer polls.Done()
+					r := poll(h)
+					if inflight != nil {
+						<-inflight
+					}
+					results <- r
+					<-slots
+				}(h)
+			}
+			polls.Wait()
+		}(queues[root])
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// rootOf returns the scheme and host of rawURL, or rawURL itself when it cannot be parsed.
+func rootOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inflightTracker records the highest number of concurrent calls seen per key.
+type inflightTracker struct {
+	mu      sync.Mutex
+	current map[string]int
+	max     map[string]int
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{current: make(map[string]int), max: make(map[string]int)}
+}
+
+func (t *inflightTracker) poll(key string, h Host) Result {
+	t.mu.Lock()
+	t.current[key]++
+	if t.current[key] > t.max[key] {
+		t.max[key] = t.current[key]
+	}
+	t.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.mu.Lock()
+	t.current[key]--
+	t.mu.Unlock()
+	return Result{Host: h}
+}
+
+func TestSchedulerReturnsResultForEveryHost(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var hosts []Host
+	for i := 0; i < 100; i++ {
+		hosts = append(hosts, Host{Name: fmt.Sprintf("host%d", i), URL: fmt.Sprintf("http://root.com/host%d/status", i)})
+	}
+
+	sched := Scheduler{Concurrency: 7}
+	seen := make(map[string]bool)
+	for r := range sched.Run(hosts, func(h Host) Result { return Result{Host: h} }) {
+		seen[r.Host.Name] = true
+	}
+	assert.Len(seen, len(hosts))
+}
+
+func TestSchedulerLimitsConcurrency(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		concurrency int
+	}{
+		{1},
+		{4},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.concurrency), func(t *testing.T) {
+			var hosts []Host
+			for i := 0; i < 20; i++ {
+				hosts = append(hosts, Host{URL: fmt.Sprintf("http://root%d.com/host/status", i)})
+			}
+
+			tracker := newInflightTracker()
+			sched := Scheduler{Concurrency: tt.concurrency}
+			for range sched.Run(hosts, func(h Host) Result { return tracker.poll("all", h) }) {
+			}
+			assert.True(tracker.max["all"] <= tt.concurrency)
+		})
+	}
+}
+
+func TestSchedulerLimitsInflightPerRoot(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var hosts []Host
+	for i := 0; i < 20; i++ {
+		hosts = append(hosts, Host{URL: fmt.Sprintf("http://root%d.com/host%d/status", i%2, i)})
+	}
+
+	tracker := newInflightTracker()
+	sched := Scheduler{Concurrency: 10, MaxInflightPerRoot: 2}
+	for range sched.Run(hosts, func(h Host) Result { return tracker.poll(rootOf(h.URL), h) }) {
+	}
+	assert.True(tracker.max["http://root0.com"] <= 2)
+	assert.True(tracker.max["http://root1.com"] <= 2)
+}
+
+func TestSchedulerDoesNotHoldWorkersForBusyRoots(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	hosts := []Host{
+		{Name: "a1", URL: "http://a.com/a1/status"},
+		{Name: "a2", URL: "http://a.com/a2/status"},
+		{Name: "a3", URL: "http://a.com/a3/status"},
+		{Name: "b1", URL: "http://b.com/b1/status"},
+	}
+
+	// hosts of root a only complete once b1 has been polled, which can only happen when no
+	// worker is left waiting on root a
+	polledB := make(chan struct{})
+	sched := Scheduler{Concurrency: 2, MaxInflightPerRoot: 1}
+	results := sched.Run(hosts, func(h Host) Result {
+		if h.Name == "b1" {
+			close(polledB)
+		} else {
+			<-polledB
+		}
+		return Result{Host: h}
+	})
+
+	seen := make(map[string]bool)
+	timeout := time.After(5 * time.Second)
+	for len(seen) < len(hosts) {
+		select {
+		case r := <-results:
+			seen[r.Host.Name] = true
+		case <-timeout:
+			t.Fatalf("only %d of %d hosts were polled", len(seen), len(hosts))
+		}
+	}
+	assert.Len(seen, len(hosts))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	defaultListenAddr   = ":9273"
+	defaultPollInterval = time.Minute
+	shutdownTimeout     = 10 * time.Second
+)
+
+// Exporter serves the most recently completed report over HTTP.  Requests are always answered
+// from that report and never trigger a poll.
+type Exporter struct {
+	mu     sync.RWMutex
+	report *Report
+}
+
+// Update replaces the report served by the exporter.
+func (e *Exporter) Update(r *Report) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.report = r
+}
+
+// Report returns the report served by the exporter, or nil before the first poll completes.
+func (e *Exporter) Report() *Report {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.report
+}
+
+// Handler returns the HTTP handler serving /metrics, /report and /healthz.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.serveRendered("text/plain; version=0.0.4; charset=utf-8", renderPrometheus))
+	mux.HandleFunc("/report", e.serveRendered("application/json", renderJSON))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// serveRendered returns a handler which renders the latest report with render.
+func (e *Exporter) serveRendered(contentType string, render RendererFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := e.Report()
+		if report == nil {
+			http.Error(w, "no poll has completed yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := render(&buf, report); err != nil {
+			log.WithError(err).Error("unable to render report")
+			http.Error(w, "unable to render report", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if _, err := buf.WriteTo(w); err != nil {
+			log.WithError(err).Debug("unable to write response")
+		}
+	}
+}
+
+// runServe polls every host on an interval and serves the latest report over HTTP until
+// interrupted.
+func runServe(flag Flag) {
+	poller, err := NewPoller(flag)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create poller")
+	}
+
+	exporter := &Exporter{}
+	srv := &http.Server{Addr: flag.Listen, Handler: exporter.Handler()}
+	go func() {
+		log.Infof("serving metrics on %s", flag.Listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Fatal("unable to serve metrics")
+		}
+	}()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	ticker := time.NewTicker(flag.Interval)
+	defer ticker.Stop()
+
+	pollEvery(ctx, flag, flag.Interval, ticker.C, poller, func(r *Report) {
+		exporter.Update(r)
+		log.Infof("poll completed, %d/%d hosts reported in %s", r.HostsReported, r.HostsTotal, r.Duration.Truncate(time.Millisecond))
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("unable to shut down server cleanly")
+	}
+}
+
+// pollEvery polls every host immediately and then on every tick until ctx is cancelled, calling
+// done with each completed report.  The hosts files are read again, and the discovery sources
+// resolved again, for every poll, and each poll is limited to the run timeout, or to the interval
+// between ticks when no run timeout is given.
+func pollEvery(ctx context.Context, flag Flag, interval time.Duration, ticks <-chan time.Time, poller *Poller, done func(*Report)) {
+	inventory, err := NewFlagInventory(flag)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create inventory")
+	}
+	for {
+		hosts, err := inventory.Load()
+		if err != nil {
+			log.WithError(err).Error("unable to load hosts")
+		} else {
+			timeout := flag.RunTimeout
+			if timeout <= 0 {
+				timeout = interval
+			}
+			pollCtx, cancel := context.WithTimeout(ctx, timeout)
+			report := poller.Poll(pollCtx, hosts)
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+			saveHistory(flag, report)
+			done(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+	}
+}
+
+// interruptContext returns a context which is cancelled when the process receives SIGINT or
+// SIGTERM, along with a func releasing the signal handler.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			log.Info("shutting down")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
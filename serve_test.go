@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExporterServesLatestReport(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	exporter := &Exporter{}
+	ts := httptest.NewServer(exporter.Handler())
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(ts.URL + path)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(err)
+		return resp.StatusCode, string(b)
+	}
+
+	code, _ := get("/healthz")
+	assert.Equal(http.StatusOK, code)
+	code, _ = get("/metrics")
+	assert.Equal(http.StatusServiceUnavailable, code)
+	code, _ = get("/report")
+	assert.Equal(http.StatusServiceUnavailable, code)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Status: HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 10, SuccessCount: 9}})
+	exporter.Update(r)
+
+	code, body := get("/metrics")
+	assert.Equal(http.StatusOK, code)
+	assert.True(strings.Contains(body, `statusrep_app_requests{application="app1",version="1.0.0"} 10`))
+
+	code, body = get("/report")
+	assert.Equal(http.StatusOK, code)
+	var doc ReportDocument
+	assert.Nil(json.Unmarshal([]byte(body), &doc))
+	assert.Equal("app1", doc.Applications[0].Name)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink is a destination reports are written to.
+type Sink interface {
+	Write(r *Report) error
+}
+
+// WriterSink renders reports to a writer, such as stdout.
+type WriterSink struct {
+	W        io.Writer
+	Renderer Renderer
+}
+
+// Write renders r to the sink's writer.
+func (s *WriterSink) Write(r *Report) error {
+	return s.Renderer.Render(s.W, r)
+}
+
+// FileSink renders reports to a file.  The file is replaced atomically, so that readers never
+// see a partially written report.
+type FileSink struct {
+	Path     string
+	Renderer Renderer
+}
+
+// Write renders r to a temporary file alongside the sink's path, then renames it into place.
+func (s *FileSink) Write(r *Report) error {
+	return writeFileAtomic(s.Path, func(w io.Writer) error {
+		return s.Renderer.Render(w, r)
+	})
+}
+
+// writeFileAtomic calls write with a temporary file in the same directory as path, and renames
+// the temporary file to path once write succeeds.  The temporary file is removed on failure.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create temporary file for '%s'", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to sync '%s'", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close '%s'", tmp.Name())
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrapf(err, "unable to set permissions of '%s'", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "unable to move report into place at '%s'", path)
+	}
+	return nil
+}
+
+// OutputSpec describes a single report output, as given by --output.
+type OutputSpec struct {
+	// Format is the output format of the report.
+	Format string
+	// Path is the file the report is written to.  The report is written to stdout when Path is
+	// empty or "-".
+	Path string
+	// Template is the text/template used by the template format.
+	Template string
+}
+
+// ParseOutputSpecs parses the values of every --output flag.  Each output is a list of key=value
+// settings starting with format, such as "format=json,path=report.json".  Values are expected
+// to have already been split on commas, so a new output starts at every format key, and no
+// setting may hold a comma.  Fragments which are not key=value, unknown keys and keys given
+// twice for one output are rejected, as are several outputs written to stdout, which would
+// interleave.
+func ParseOutputSpecs(values []string) ([]OutputSpec, error) {
+	var specs []OutputSpec
+	var seen map[string]bool
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			if len(specs) > 0 {
+				return nil, errors.Errorf("output setting '%s' must be given as key=value, output settings cannot contain commas", v)
+			}
+			return nil, errors.Errorf("output setting '%s' must be given as key=value", v)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		if value == "" {
+			return nil, errors.Errorf("output setting '%s' has no value", key)
+		}
+
+		if key == "format" {
+			if !validOutputFormat(value) {
+				return nil, errors.Errorf("unknown output format '%s', expected one of %s", value, strings.Join(outputFormats, ", "))
+			}
+			specs = append(specs, OutputSpec{Format: value})
+			seen = map[string]bool{key: true}
+			continue
+		}
+		if len(specs) == 0 {
+			return nil, errors.Errorf("output setting '%s' given before format, each output must start with format=", v)
+		}
+		if seen[key] {
+			return nil, errors.Errorf("output setting '%s' is given more than once for the same output", key)
+		}
+		seen[key] = true
+
+		spec := &specs[len(specs)-1]
+		switch key {
+		case "path":
+			spec.Path = value
+		case "template":
+			spec.Template = value
+		default:
+			return nil, errors.Errorf("unknown output setting '%s', expected one of format, path, template", key)
+		}
+	}
+
+	var stdout []string
+	for _, spec := range specs {
+		if spec.Path == "" || spec.Path == "-" {
+			stdout = append(stdout, spec.Format)
+		}
+	}
+	if len(stdout) > 1 {
+		return nil, errors.Errorf("outputs %s are all written to stdout, only one output may be written to stdout", strings.Join(stdout, ", "))
+	}
+	return specs, nil
+}
+
+// NewSinks creates a Sink for every spec.  Reports written to stdout are coloured according to
+// colorMode, while reports written to files are only coloured when colorMode is "always".
+func NewSinks(specs []OutputSpec, opts RenderOptions, colorMode string) ([]Sink, error) {
+	var sinks []Sink
+	for _, spec := range specs {
+		specOpts := opts
+		if spec.Template != "" {
+			specOpts.Template = spec.Template
+		}
+
+		toStdout := spec.Path == "" || spec.Path == "-"
+		var f *os.File
+		if toStdout {
+			f = os.Stdout
+		}
+		color, err := ColorEnabled(colorMode, f)
+		if err != nil {
+			return nil, err
+		}
+		specOpts.Table.Color = color
+
+		renderer, err := NewRenderer(spec.Format, specOpts)
+		if err != nil {
+			return nil, err
+		}
+		if toStdout {
+			sinks = append(sinks, &WriterSink{W: os.Stdout, Renderer: renderer})
+		} else {
+			sinks = append(sinks, &FileSink{Path: spec.Path, Renderer: renderer})
+		}
+	}
+	return sinks, nil
+}
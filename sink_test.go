@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsingOutputSpecs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		values   []string
+		expSpecs []OutputSpec
+		expErr   bool
+	}{
+		{"stdout", []string{"format=table"}, []OutputSpec{{Format: "table"}}, false},
+		{"file", []string{"format=json", "path=report.json"}, []OutputSpec{{Format: "json", Path: "report.json"}}, false},
+		{
+			"several",
+			[]string{"format=table", "path=report.txt", "format=json", "path=report.json", "format=template", "template=slack.tmpl", "path=-"},
+			[]OutputSpec{{Format: "table", Path: "report.txt"}, {Format: "json", Path: "report.json"}, {Format: "template", Template: "slack.tmpl", Path: "-"}},
+			false,
+		},
+		{"several_to_stdout", []string{"format=json", "format=csv"}, nil, true},
+		{"several_to_stdout_by_dash", []string{"format=json", "format=csv", "path=-"}, nil, true},
+		{"setting_before_format", []string{"path=report.json", "format=json"}, nil, true},
+		{"unknown_format", []string{"format=xml"}, nil, true},
+		{"unknown_setting", []string{"format=json", "mode=0600"}, nil, true},
+		{"missing_value", []string{"format"}, nil, true},
+		{"empty_value", []string{"format=json", "path="}, nil, true},
+		{"comma_in_path", []string{"format=csv", "path=a", "b.csv"}, nil, true},
+		{"repeated_setting", []string{"format=csv", "path=a.csv", "path=b.csv"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := ParseOutputSpecs(tt.values)
+			assert.Equal(tt.expErr, err != nil)
+			assert.Equal(tt.expSpecs, specs)
+		})
+	}
+}
+
+func TestFileSinkReplacesFileAtomically(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.txt")
+	assert.Nil(ioutil.WriteFile(path, []byte("previous"), 0644))
+
+	failing := &FileSink{Path: path, Renderer: RendererFunc(func(w io.Writer, r *Report) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("render failed")
+	})}
+	assert.NotNil(failing.Write(NewReport(nil)))
+
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("previous", string(b))
+
+	working := &FileSink{Path: path, Renderer: RendererFunc(func(w io.Writer, r *Report) error {
+		_, err := io.WriteString(w, "current")
+		return err
+	})}
+	assert.Nil(working.Write(NewReport(nil)))
+
+	b, err = ioutil.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("current", string(b))
+
+	entries, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(entries, 1)
+}
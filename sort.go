@@ -0,0 +1,186 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortFields maps each sort key name to a comparison of two rows, returning a negative number
+// when a sorts before b, a positive number when b sorts before a and zero when they are equal.
+var sortFields = map[string]func(a, b AppRow) int{
+	"name":         func(a, b AppRow) int { return strings.Compare(a.App.Name, b.App.Name) },
+	"version":      func(a, b AppRow) int { return compareVersions(a.App.Version, b.App.Version) },
+	"success-rate": func(a, b AppRow) int { return compareFloats(a.Metric.SuccessRate(), b.Metric.SuccessRate()) },
+	"error-rate":   func(a, b AppRow) int { return compareFloats(a.Metric.ErrorRate(), b.Metric.ErrorRate()) },
+	"requests":     func(a, b AppRow) int { return compareUints(a.Metric.TotalRequestsCount, b.Metric.TotalRequestsCount) },
+	"successes":    func(a, b AppRow) int { return compareUints(a.Metric.TotalSuccessCount, b.Metric.TotalSuccessCount) },
+	"errors":       func(a, b AppRow) int { return compareUints(a.Metric.TotalErrorCount, b.Metric.TotalErrorCount) },
+}
+
+// defaultSortKeys orders rows by name then version, and breaks any remaining ties.
+var defaultSortKeys = []SortKey{{Field: "name"}, {Field: "version"}}
+
+// SortKey is a single field rows are ordered by.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// sortFieldNames returns the names of every sortable field.
+func sortFieldNames() []string {
+	names := make([]string, 0, len(sortFields))
+	for n := range sortFields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSortKeys parses sort keys given on the command line.  Each key is a field name,
+// optionally followed by ":asc" or ":desc", or prefixed with "-" for descending order.
+func ParseSortKeys(keys []string) ([]SortKey, error) {
+	var parsed []SortKey
+	for _, k := range keys {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			continue
+		}
+
+		var key SortKey
+		if strings.HasPrefix(k, "-") {
+			key.Descending = true
+			k = k[1:]
+		}
+		if i := strings.LastIndex(k, ":"); i >= 0 {
+			switch k[i+1:] {
+			case "asc":
+			case "desc":
+				key.Descending = true
+			default:
+				return nil, errors.Errorf("unknown sort direction '%s', expected asc or desc", k[i+1:])
+			}
+			k = k[:i]
+		}
+		k = strings.Replace(k, "_", "-", -1)
+		if _, ok := sortFields[k]; !ok {
+			return nil, errors.Errorf("unknown sort key '%s', expected one of %s", k, strings.Join(sortFieldNames(), ", "))
+		}
+		key.Field = k
+		parsed = append(parsed, key)
+	}
+	return parsed, nil
+}
+
+// SortRows orders rows by each key in turn, using later keys to break ties.  Rows which are
+// still equal are ordered by name and version, and then by their group, so that the order is
+// always deterministic.
+func SortRows(rows []AppRow, keys []SortKey) {
+	keys = append(append([]SortKey{}, keys...), defaultSortKeys...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			c := sortFields[k.Field](rows[i], rows[j])
+			if k.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return compareGroups(rows[i].Group, rows[j].Group) < 0
+	})
+}
+
+// compareGroups compares the values of two groups dimension by dimension.
+func compareGroups(a, b []Dimension) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareVersions compares two versions, treating them as semantic versions where possible so
+// that "1.10.0" sorts after "1.9.3".  A leading "v" and build metadata are ignored, and a
+// pre-release sorts before the release it precedes.
+func compareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	if c := compareDotted(aCore, bCore); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return strings.Compare(a, b)
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareDotted(aPre, bPre)
+}
+
+// splitVersion splits a version into its dotted core and its pre-release.
+func splitVersion(v string) (string, string) {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// compareDotted compares dot separated identifiers, numerically where both are numbers.
+func compareDotted(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bParts[i], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareUint64s(aNum, bNum)
+		case aErr == nil:
+			// numeric identifiers have lower precedence than alphanumeric ones
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(aParts[i], bParts[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUints(a, b uint) int {
+	return compareUint64s(uint64(a), uint64(b))
+}
+
+func compareUint64s(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
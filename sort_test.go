@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestComparingVersions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		a, b   string
+		expCmp int
+	}{
+		{"1.9.3", "1.10.0", -1},
+		{"v2.0.0", "1.10.0", 1},
+		{"v1.2.0+build5", "1.2.1", -1},
+		{"1.2.0", "1.2.0", 0},
+		{"1.2.0-rc.1", "1.2.0", -1},
+		{"1.2.0-rc.2", "1.2.0-rc.10", -1},
+		{"1.2.0-alpha", "1.2.0-1", 1},
+		{"1.2", "1.2.1", -1},
+		{"ver1.2", "ver1.3", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			c := compareVersions(tt.a, tt.b)
+			switch {
+			case tt.expCmp < 0:
+				assert.True(c < 0)
+			case tt.expCmp > 0:
+				assert.True(c > 0)
+			default:
+				assert.Equal(0, c)
+			}
+		})
+	}
+}
+
+func TestParsingSortKeys(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	tests := []struct {
+		name    string
+		keys    []string
+		expKeys []SortKey
+		expErr  bool
+	}{
+		{"plain", []string{"name"}, []SortKey{{Field: "name"}}, false},
+		{"directions", []string{"success-rate:desc", "version:asc"}, []SortKey{{Field: "success-rate", Descending: true}, {Field: "version"}}, false},
+		{"dash_prefix", []string{"-requests"}, []SortKey{{Field: "requests", Descending: true}}, false},
+		{"underscore", []string{"error_rate"}, []SortKey{{Field: "error-rate"}}, false},
+		{"unknown_key", []string{"latency"}, nil, true},
+		{"unknown_direction", []string{"name:up"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseSortKeys(tt.keys)
+			assert.Equal(tt.expErr, err != nil)
+			assert.Equal(tt.expKeys, keys)
+		})
+	}
+}
+
+func TestSortingRows(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	rows := func() []AppRow {
+		return []AppRow{
+			{App: Application{"b", "1.10.0"}, Metric: Metric{TotalRequestsCount: 10, TotalSuccessCount: 9}},
+			{App: Application{"a", "1.9.0"}, Metric: Metric{TotalRequestsCount: 30, TotalSuccessCount: 15}},
+			{App: Application{"b", "1.9.0"}, Metric: Metric{TotalRequestsCount: 20, TotalSuccessCount: 18}},
+			{App: Application{"a", "1.10.0"}, Metric: Metric{TotalRequestsCount: 20, TotalSuccessCount: 20}},
+		}
+	}
+	order := func(rows []AppRow) []Application {
+		var apps []Application
+		for _, r := range rows {
+			apps = append(apps, r.App)
+		}
+		return apps
+	}
+
+	tests := []struct {
+		name     string
+		keys     []SortKey
+		expOrder []Application
+	}{
+		{"default", nil, []Application{{"a", "1.9.0"}, {"a", "1.10.0"}, {"b", "1.9.0"}, {"b", "1.10.0"}}},
+		{"version_desc", []SortKey{{Field: "version", Descending: true}}, []Application{{"a", "1.10.0"}, {"b", "1.10.0"}, {"a", "1.9.0"}, {"b", "1.9.0"}}},
+		{"success_rate_then_requests", []SortKey{{Field: "success-rate"}, {Field: "requests", Descending: true}}, []Application{{"a", "1.9.0"}, {"b", "1.9.0"}, {"b", "1.10.0"}, {"a", "1.10.0"}}},
+		{"requests_tie_broken_by_name", []SortKey{{Field: "requests"}}, []Application{{"b", "1.10.0"}, {"a", "1.10.0"}, {"b", "1.9.0"}, {"a", "1.9.0"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rows()
+			SortRows(r, tt.keys)
+			assert.Equal(tt.expOrder, order(r))
+		})
+	}
+}
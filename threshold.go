@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// Thresholds are the limits a report must stay within to pass.
+type Thresholds struct {
+	// MinSuccessRate is the lowest success rate allowed for any application version.  Zero
+	// means no limit.
+	MinSuccessRate float64
+	// AppMinSuccessRates overrides MinSuccessRate for single applications or application
+	// versions, keyed by Application.  An empty version applies to every version.
+	AppMinSuccessRates map[Application]float64
+	// MaxFailedHosts is the largest number of hosts allowed to fail.  Negative means no limit.
+	MaxFailedHosts int
+	// MaxFailedHostRatio is the largest fraction of hosts allowed to fail.  Negative means no limit.
+	MaxFailedHostRatio float64
+	// Window checks success rates over the interval since the previous poll, where known,
+	// rather than lifetime success rates.
+	Window bool
+}
+
+// Violation describes a threshold which a report did not stay within.
+type Violation struct {
+	// Rule is the threshold violated.
+	Rule string
+	// Message describes the violation.
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Message
+}
+
+// ParseAppMinSuccessRates parses per application success rate limits given as NAME=RATE, or
+// NAME@VERSION=RATE to limit a single version.
+func ParseAppMinSuccessRates(values []string) (map[Application]float64, error) {
+	rates := make(map[Application]float64)
+	for _, v := range values {
+		i := strings.LastIndex(v, "=")
+		if i < 0 {
+			return nil, errors.Errorf("application success rate '%s' must be given as NAME[@VERSION]=RATE", v)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(v[i+1:]), 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, errors.Errorf("application success rate '%s' must be between 0 and 1", v)
+		}
+
+		var app Application
+		app.Name = strings.TrimSpace(v[:i])
+		if j := strings.LastIndex(app.Name, "@"); j >= 0 {
+			app.Name, app.Version = strings.TrimSpace(app.Name[:j]), strings.TrimSpace(app.Name[j+1:])
+		}
+		if app.Name == "" {
+			return nil, errors.Errorf("application success rate '%s' does not name an application", v)
+		}
+		rates[app] = rate
+	}
+	return rates, nil
+}
+
+// Enabled reports whether any threshold is set, so that a report can fail to stay within them.
+func (t Thresholds) Enabled() bool {
+	return t.MinSuccessRate > 0 || len(t.AppMinSuccessRates) > 0 || t.MaxFailedHosts >= 0 || t.MaxFailedHostRatio >= 0
+}
+
+// minSuccessRate returns the lowest success rate allowed for app.
+func (t Thresholds) minSuccessRate(app Application) float64 {
+	if rate, ok := t.AppMinSuccessRates[app]; ok {
+		return rate
+	}
+	if rate, ok := t.AppMinSuccessRates[Application{Name: app.Name}]; ok {
+		return rate
+	}
+	return t.MinSuccessRate
+}
+
+// Check returns every threshold r does not stay within.  Application versions without any
+// requests are not checked against success rate limits.
+func (t Thresholds) Check(r *Report) []Violation {
+	var violations []Violation
+	for _, row := range r.Rows() {
+		min := t.minSuccessRate(row.App)
+		if min <= 0 {
+			continue
+		}
+		m, kind := row.Metric, "success rate"
+		if t.Window && row.Window != nil && row.Window.TotalRequestsCount > 0 {
+			m, kind = *row.Window, "window success rate"
+		}
+		if m.TotalRequestsCount > 0 && m.SuccessRate() < min {
+			name := strings.TrimSpace(row.App.Name + " " + row.App.Version)
+			if len(row.Group) > 0 {
+				dims := make([]string, len(row.Group))
+				for i, d := range row.Group {
+					dims[i] = d.Name + "=" + d.Value
+				}
+				name += " (" + strings.Join(dims, ", ") + ")"
+			}
+			violations = append(violations, Violation{
+				Rule:    "min-success-rate",
+				Message: fmt.Sprintf("%s %s %s is below %s", name, kind, formatPercent(m.SuccessRate()), formatPercent(min)),
+			})
+		}
+	}
+
+	failed := len(r.FailedHosts)
+	if t.MaxFailedHosts >= 0 && failed > t.MaxFailedHosts {
+		violations = append(violations, Violation{
+			Rule:    "max-failed-hosts",
+			Message: fmt.Sprintf("%d hosts failed, more than the %d allowed", failed, t.MaxFailedHosts),
+		})
+	}
+	if t.MaxFailedHostRatio >= 0 && r.HostsTotal > 0 {
+		ratio := float64(failed) / float64(r.HostsTotal)
+		if ratio > t.MaxFailedHostRatio {
+			violations = append(violations, Violation{
+				Rule:    "max-failed-host-ratio",
+				Message: fmt.Sprintf("%s of hosts failed, more than the %s allowed", formatPercent(ratio), formatPercent(t.MaxFailedHostRatio)),
+			})
+		}
+	}
+	return violations
+}
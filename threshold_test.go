@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsingAppMinSuccessRates(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	rates, err := ParseAppMinSuccessRates([]string{"app1=0.9", "app2@1.2.0=0.95", " app3 @ v2 = 1"})
+	assert.Nil(err)
+	assert.Equal(map[Application]float64{
+		{Name: "app1"}:                   0.9,
+		{Name: "app2", Version: "1.2.0"}: 0.95,
+		{Name: "app3", Version: "v2"}:    1,
+	}, rates)
+
+	for _, v := range []string{"app1", "app1=high", "app1=1.5", "=0.9", "@1.0.0=0.9"} {
+		_, err := ParseAppMinSuccessRates([]string{v})
+		assert.NotNil(err, v)
+	}
+}
+
+func TestThresholdsAreEnabledByAnyLimit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	none := Thresholds{MaxFailedHosts: -1, MaxFailedHostRatio: -1}
+	assert.False(none.Enabled())
+
+	for _, th := range []Thresholds{
+		{MinSuccessRate: 0.9, MaxFailedHosts: -1, MaxFailedHostRatio: -1},
+		{AppMinSuccessRates: map[Application]float64{{Name: "app1"}: 0.9}, MaxFailedHosts: -1, MaxFailedHostRatio: -1},
+		{MaxFailedHosts: 0, MaxFailedHostRatio: -1},
+		{MaxFailedHosts: -1, MaxFailedHostRatio: 0.1},
+	} {
+		assert.True(th.Enabled(), "%+v", th)
+	}
+}
+
+func TestCheckingThresholds(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := tableTestReport()
+	r.Add(Result{Host: Host{Name: "down1"}, Err: ErrTimeout})
+	r.Add(Result{Status: HostStatus{Application: "app3", Version: "1.0.0"}})
+
+	rules := func(violations []Violation) []string {
+		var names []string
+		for _, v := range violations {
+			names = append(names, v.Rule)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		expRules   []string
+	}{
+		{"no limits", Thresholds{MaxFailedHosts: -1, MaxFailedHostRatio: -1}, nil},
+		{"min success rate", Thresholds{MinSuccessRate: 0.9, MaxFailedHosts: -1, MaxFailedHostRatio: -1}, []string{"min-success-rate"}},
+		{
+			"application override",
+			Thresholds{MinSuccessRate: 0.9, AppMinSuccessRates: map[Application]float64{{Name: "app2"}: 0.5}, MaxFailedHosts: -1, MaxFailedHostRatio: -1},
+			nil,
+		},
+		{
+			"version override",
+			Thresholds{MinSuccessRate: 0.5, AppMinSuccessRates: map[Application]float64{{Name: "app1"}: 0.5, {Name: "app1", Version: "1.2.0"}: 0.999}, MaxFailedHosts: -1, MaxFailedHostRatio: -1},
+			[]string{"min-success-rate"},
+		},
+		{"no failed hosts allowed", Thresholds{MaxFailedHosts: 0, MaxFailedHostRatio: -1}, []string{"max-failed-hosts"}},
+		{"one failed host allowed", Thresholds{MaxFailedHosts: 1, MaxFailedHostRatio: -1}, nil},
+		{"failed host ratio", Thresholds{MaxFailedHosts: -1, MaxFailedHostRatio: 0.2}, []string{"max-failed-host-ratio"}},
+		{"every rule", Thresholds{MinSuccessRate: 0.999, MaxFailedHosts: 0, MaxFailedHostRatio: 0}, []string{"min-success-rate", "min-success-rate", "max-failed-hosts", "max-failed-host-ratio"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(tt.expRules, rules(tt.thresholds.Check(r)))
+		})
+	}
+}
+
+func TestCheckingWindowSuccessRates(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := tableTestReport()
+	r.Windows[Application{Name: "app1", Version: "1.2.0"}] = Metric{TotalRequestsCount: 100, TotalSuccessCount: 50}
+
+	thresholds := Thresholds{MinSuccessRate: 0.7, MaxFailedHosts: -1, MaxFailedHostRatio: -1}
+	assert.Empty(thresholds.Check(r))
+
+	thresholds.Window = true
+	violations := thresholds.Check(r)
+	if assert.Len(violations, 1) {
+		assert.Equal("app1 1.2.0 window success rate 50.00% is below 70.00%", violations[0].Message)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"time"
+)
+
+// ansiClearScreen moves the cursor to the top left corner and clears the screen.
+var ansiClearScreen = "\x1b[H\x1b[2J"
+
+// runWatch polls every host on the watch interval, redrawing the table report after each poll
+// along with the changes since the previous poll, until interrupted.
+func runWatch(flag Flag) {
+	poller, err := NewPoller(flag)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create poller")
+	}
+
+	color, err := ColorEnabled(flag.Color, os.Stdout)
+	if err != nil {
+		log.WithError(err).Fatal("invalid color mode")
+	}
+	view := &WatchView{
+		W:        os.Stdout,
+		Interval: flag.Watch,
+		Redraw:   isTerminal(os.Stdout),
+		Options:  TableOptions{Color: color, WarnBelow: flag.WarnBelow},
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	ticker := time.NewTicker(flag.Watch)
+	defer ticker.Stop()
+
+	pollEvery(ctx, flag, flag.Watch, ticker.C, poller, func(r *Report) {
+		if err := view.Draw(r); err != nil {
+			log.WithError(err).Error("unable to draw report")
+		}
+	})
+}
+
+// WatchView draws the table report of every poll in watch mode, with the changes since the
+// report drawn before it.
+type WatchView struct {
+	W        io.Writer
+	Interval time.Duration
+	// Redraw clears the screen before drawing each report, rather than writing it below the last.
+	Redraw bool
+	// Options are the table options of every report.  Previous is set by Draw.
+	Options TableOptions
+
+	prev *Report
+}
+
+// Draw writes r, as a table preceded by a line summarizing the poll, in a single write.
+func (v *WatchView) Draw(r *Report) error {
+	var buf bytes.Buffer
+	if v.Redraw {
+		buf.WriteString(ansiClearScreen)
+	}
+	fmt.Fprintf(&buf, "every %s: %d/%d hosts reported at %s in %s\n\n",
+		v.Interval, r.HostsReported, r.HostsTotal, r.Start.Format("15:04:05"), r.Duration.Truncate(time.Millisecond))
+	opts := v.Options
+	opts.Previous = v.prev
+	if err := renderTable(&buf, r, opts); err != nil {
+		return err
+	}
+	if !v.Redraw {
+		buf.WriteString("\n")
+	}
+	if _, err := buf.WriteTo(v.W); err != nil {
+		return err
+	}
+	v.prev = r
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchRedrawsTableOnEveryTick(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var requests uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint32(&requests, 1)
+		_, err := fmt.Fprintf(w, `{"application": "app1", "Version": "1.0.0", "requests_count": %d, "success_count": %d, "error_count": %d}`, n*100, n*100-n*n, n*n)
+		assert.Nil(err)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "statusrep")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	hostsFile := filepath.Join(dir, "hosts.txt")
+	assert.Nil(ioutil.WriteFile(hostsFile, []byte("host1\n"), 0644))
+
+	flag := Flag{HostsFiles: []string{hostsFile}, RootURL: ts.URL}
+	poller := &Poller{Scheduler: &Scheduler{Concurrency: 1}, Retry: RetryPolicy{MaxAttempts: 1}, RootURL: ts.URL}
+
+	var buf bytes.Buffer
+	view := &WatchView{W: &buf, Interval: 10 * time.Second, Redraw: true}
+	drawn := make(chan struct{})
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pollEvery(ctx, flag, view.Interval, ticks, poller, func(r *Report) {
+			assert.Nil(view.Draw(r))
+			drawn <- struct{}{}
+		})
+	}()
+
+	<-drawn
+	first := buf.String()
+	buf.Reset()
+	ticks <- time.Now()
+	<-drawn
+	second := buf.String()
+	cancel()
+	<-stopped
+
+	assert.True(strings.HasPrefix(first, ansiClearScreen+"every 10s: 1/1 hosts reported at "), first)
+	assert.NotContains(first, "TREND")
+	assert.True(strings.HasPrefix(second, ansiClearScreen+"every 10s: 1/1 hosts reported at "), second)
+	lines := strings.Split(second, "\n")
+	if assert.Len(lines, 5, second) {
+		assert.Contains(lines[2], "+REQUESTS")
+		assert.Contains(lines[2], "TREND")
+		assert.Contains(lines[3], "+100")
+		assert.Contains(lines[3], "↓ 1.00pp")
+	}
+}
+
+func TestWatchAppendsReportsWhenNotRedrawing(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := NewReport(make(map[Application]Metric))
+	r.Add(Result{Host: Host{Name: "host1"}, Status: HostStatus{Application: "app1", Version: "1.0.0", RequestsCount: 10, SuccessCount: 10}})
+
+	var buf bytes.Buffer
+	view := &WatchView{W: &buf, Interval: time.Second}
+	assert.Nil(view.Draw(r))
+	assert.Nil(view.Draw(r))
+
+	out := buf.String()
+	assert.NotContains(out, ansiClearScreen)
+	assert.Equal(2, strings.Count(out, "every 1s: 1/1 hosts reported"))
+	assert.True(strings.HasSuffix(out, "\n\n"))
+}